@@ -1,121 +1,349 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"sort"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/mitsu-ksgr/vhstatus/internal/config"
+	"github.com/mitsu-ksgr/vhstatus/internal/notifier"
 	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
 	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
 	"github.com/mitsu-ksgr/vhstatus/internal/web"
 )
 
-var vhs = vhstatus.New()
+var notifiers []notifier.Notifier
 
-func getenv(key, default_value string) string {
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return default_value
-	}
-	return value
-}
+// notifySince is the time to start notifying the events,
+// so that the events replayed from the past logs are not notified.
+var notifySince = time.Now().Add(-time.Minute)
 
-func log2store(event vhlogwatcher.VHLogEvent) {
-	switch event.Event {
-	//---------------------------------------------------------------------
-	// Server Event
-	case vhlogwatcher.GameServerConnected:
-		vhs.SetStatus("Online")
+// stringList is a flag which can be specified multiple times.
+type stringList []string
 
-	case vhlogwatcher.GameServerConnectedFailed, vhlogwatcher.GameServerDisconnected:
-		vhs.SetStatus("Offile")
-
-	case vhlogwatcher.ValheimVersion:
-		vhs.SetValheimVersion(event.Value)
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	case vhlogwatcher.ServerID:
-		vhs.SetServerID(event.Value)
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
 
-	case vhlogwatcher.LoadWorld:
-		vhs.SetWorldName(event.Value)
+// notify sends the event of the server to the notifiers.
+// params is only called if the event is notified, as it is costly to build
+// for every event of the past logs.
+func notify(server string, event vhlogwatcher.VHLogEvent, params func() vhstatus.Params) {
+	if len(notifiers) == 0 || event.Timestamp.Before(notifySince) {
+		return
+	}
 
-	case vhlogwatcher.InitWorldGenSeed:
-		vhs.SetWorldSeed(event.Value)
+	p := params()
+	for _, n := range notifiers {
+		n.Notify(server, event, p)
+	}
+}
 
-	case vhlogwatcher.DayHasPassed:
-		vhs.SetDay(event.Value)
+func loadDiscordEmbeds(path string) map[string]notifier.DiscordEmbed {
+	if path == "" {
+		return nil
+	}
 
-	//---------------------------------------------------------------------
-	// User Event
-	case vhlogwatcher.Connection,
-		vhlogwatcher.GotHandshake,
-		vhlogwatcher.Disconnection:
-		vhs.UpdatePlayer(vhstatus.Player{
-			SteamID:   event.SteamID,
-			Status:    event.Event.String(),
-			UpdatedAt: event.Timestamp,
-		})
-	case vhlogwatcher.GotCharacter:
-		vhs.UpdatePlayer(vhstatus.Player{
-			SteamID:   event.SteamID,
-			Status:    event.Event.String(),
-			Name:      event.Name,
-			UpdatedAt: event.Timestamp,
-		})
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var embeds map[string]notifier.DiscordEmbed
+	if err := json.Unmarshal(data, &embeds); err != nil {
+		log.Fatalf("%s: %v", path, err)
 	}
+	return embeds
 }
 
-func getPastLogFiles(dirpath string) []string {
-	files, err := ioutil.ReadDir(dirpath)
+func loadWebhookTargets(path string) []notifier.WebhookTarget {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		log.Fatal(err)
 	}
+	var targets []notifier.WebhookTarget
+	if err := json.Unmarshal(data, &targets); err != nil {
+		log.Fatalf("%s: %v", path, err)
+	}
+	return targets
+}
 
-	logs := make([]string, 0, 1)
-	for _, file := range files {
-		if strings.HasPrefix(file.Name(), "vhserver-console-") &&
-			strings.HasSuffix(file.Name(), ".log") {
-			logs = append(logs, dirpath+"/"+file.Name())
+// parseServerFlags parses the "-server name=path/to/log/dir" flags.
+func parseServerFlags(flags []string) []config.ServerConfig {
+	var servers []config.ServerConfig
+	for _, f := range flags {
+		name, logDir := f, ""
+		if i := strings.Index(f, "="); i >= 0 {
+			name, logDir = f[:i], f[i+1:]
 		}
+		servers = append(servers, config.ServerConfig{Name: name, LogDir: logDir})
 	}
-	sort.Strings(logs)
-
-	return logs
+	return servers
 }
 
-func main() {
+// defineFlags defines the command line flags on fs, and returns the path of
+// the config file and the function to apply the flags set to the config.
+func defineFlags(fs *flag.FlagSet) (*string, func(*config.Config)) {
 	var (
+		configPath string
+
+		listen          string
 		port            string
 		pathLogDir      string
+		logGlobs        stringList
+		sourceType      string
+		sourcePath      string
 		pathTemplateDir string
+		pathData        string
+		saveInterval    time.Duration
+		slowSave        time.Duration
+
+		discordWebhooks    stringList
+		discordUsername    string
+		discordEmbeds      string
+		discordMinInterval time.Duration
+		webhookConfig      string
+
+		handshakeTimeout time.Duration
+		rulesFile        string
+		serverFlags      stringList
 	)
-	flag.StringVar(&port, "port", "8000", "http port")
-	flag.StringVar(&pathLogDir, "log-dir-path", "/home/vhserver/log/console/", "path to direcotry of vhserver-console.log")
-	flag.StringVar(&pathTemplateDir, "template-dir-path", "", "path to directory of html templates")
+	defaults := config.Default()
+	fs.StringVar(&configPath, "config", "", "path to the config file (.toml, .yaml, .yml or .json)")
+	fs.StringVar(&listen, "listen", defaults.Listen, "http listen address")
+	fs.StringVar(&port, "port", strings.TrimPrefix(defaults.Listen, ":"), "http port")
+	fs.StringVar(&pathLogDir, "log-dir-path", defaults.LogDir, "path to direcotry of vhserver-console.log")
+	fs.Var(&logGlobs, "log-glob", "file name pattern of the past log files, also read if compressed by gzip or zstd (can be specified multiple times)")
+	fs.StringVar(&sourceType, "source", defaults.Source.Type, "where the server output is read from: file, stdin, pipe or journald")
+	fs.StringVar(&sourcePath, "source-path", defaults.Source.Path, "path to the named pipe of -source pipe or journald (journald reads stdin if empty)")
+	fs.StringVar(&pathTemplateDir, "template-dir-path", defaults.TemplateDir, "path to directory of html templates")
+	fs.StringVar(&pathData, "data-path", defaults.Data.Path, "path to the file to persist the status (disabled if empty)")
+	fs.DurationVar(&saveInterval, "save-interval", time.Duration(defaults.Data.SaveInterval), "interval to persist the status")
+	fs.DurationVar(&slowSave, "slow-save-threshold", time.Duration(defaults.WorldSave.SlowThreshold), "duration of a world save to warn (disabled if 0)")
+	fs.Var(&discordWebhooks, "discord-webhook", "Discord webhook URL to notify (can be specified multiple times)")
+	fs.StringVar(&discordUsername, "discord-username", defaults.Discord.Username, "name of the Discord webhook user")
+	fs.StringVar(&discordEmbeds, "discord-embeds", defaults.Discord.EmbedsFile, "path to the JSON file of the Discord embed messages")
+	fs.DurationVar(&discordMinInterval, "discord-min-interval", time.Duration(defaults.Discord.MinInterval), "minimum interval between messages to a Discord webhook")
+	fs.StringVar(&webhookConfig, "webhook-config", defaults.Webhook.TargetsFile, "path to the JSON file of the outgoing webhook targets")
+	fs.DurationVar(&handshakeTimeout, "handshake-timeout", time.Duration(defaults.HandshakeTimeout), "time to wait for the character of a handshake")
+	fs.StringVar(&rulesFile, "rules-file", defaults.RulesFile, "path to the JSON file of the additional log patterns")
+	fs.Var(&serverFlags, "server", "name=path/to/log/dir of a server to watch (can be specified multiple times)")
+
+	apply := func(cfg *config.Config) {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "listen":
+				cfg.Listen = listen
+			case "port":
+				cfg.Listen = ":" + port
+			case "log-dir-path":
+				cfg.LogDir = pathLogDir
+			case "log-glob":
+				cfg.LogGlobs = logGlobs
+			case "source":
+				cfg.Source.Type = sourceType
+			case "source-path":
+				cfg.Source.Path = sourcePath
+			case "template-dir-path":
+				cfg.TemplateDir = pathTemplateDir
+			case "data-path":
+				cfg.Data.Path = pathData
+			case "save-interval":
+				cfg.Data.SaveInterval = config.Duration(saveInterval)
+			case "slow-save-threshold":
+				cfg.WorldSave.SlowThreshold = config.Duration(slowSave)
+			case "discord-webhook":
+				cfg.Discord.Webhooks = discordWebhooks
+			case "discord-username":
+				cfg.Discord.Username = discordUsername
+			case "discord-embeds":
+				cfg.Discord.EmbedsFile = discordEmbeds
+			case "discord-min-interval":
+				cfg.Discord.MinInterval = config.Duration(discordMinInterval)
+			case "webhook-config":
+				cfg.Webhook.TargetsFile = webhookConfig
+			case "handshake-timeout":
+				cfg.HandshakeTimeout = config.Duration(handshakeTimeout)
+			case "rules-file":
+				cfg.RulesFile = rulesFile
+			case "server":
+				cfg.Servers = parseServerFlags(serverFlags)
+			}
+		})
+	}
+	return &configPath, apply
+}
+
+func main() {
+	configPath, applyFlags := defineFlags(flag.CommandLine)
 	flag.Parse()
 
-	pathLogDir = strings.TrimSuffix(pathLogDir, "/")
-	pathLogFile := pathLogDir + "/vhserver-console.log"
+	// defaults < config file < environment variables < flags
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyFlags(&cfg)
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Setup log rules
+	// the custom events must be registered before the notifiers refer them.
+	if cfg.RulesFile != "" {
+		rules, err := vhlogwatcher.LoadRules(cfg.RulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := vhlogwatcher.SetRules(rules); err != nil {
+			log.Fatalf("%s: %v", cfg.RulesFile, err)
+		}
+	}
+
+	// Setup notifiers
+	if len(cfg.Discord.Webhooks) > 0 {
+		discord, err := notifier.NewDiscord(notifier.DiscordConfig{
+			WebhookURLs: cfg.Discord.Webhooks,
+			Username:    cfg.Discord.Username,
+			Embeds:      loadDiscordEmbeds(cfg.Discord.EmbedsFile),
+			ShowServer:  len(cfg.ServerList()) > 1,
+			Sender: notifier.SenderConfig{
+				MinInterval: time.Duration(cfg.Discord.MinInterval),
+				MaxRetries:  5,
+			},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		notifiers = append(notifiers, discord)
+	}
+	if cfg.Webhook.TargetsFile != "" {
+		webhook, err := notifier.NewWebhook(notifier.WebhookConfig{
+			Targets: loadWebhookTargets(cfg.Webhook.TargetsFile),
+			Sender:  notifier.SenderConfig{MaxRetries: 5},
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		notifiers = append(notifiers, webhook)
+	}
+
+	// Setup servers
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var servers []*server
+	var webServers []web.Server
+	for _, sc := range cfg.ServerList() {
+		s := newServer(sc.Name, sc.LogDir, sc.DataPath, time.Duration(cfg.HandshakeTimeout))
+		s.vhs.SetSlowSaveThreshold(time.Duration(cfg.WorldSave.SlowThreshold))
+		s.logGlobs = cfg.LogGlobs
+		s.source = newSource(sc.Source)
+		servers = append(servers, s)
+		webServers = append(webServers, s.webServer())
+	}
 
-	// Setup data store
-	for _, f := range getPastLogFiles(pathLogDir) {
-		vhlogwatcher.ReadVHLog(f, log2store)
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		if err := s.start(ctx, &wg); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Fatalf("%s: %v", s.name, err)
+		}
+		s.saveSnapshot()
 	}
-	go vhlogwatcher.WatchVHLog(pathLogFile, log2store)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Data.SaveInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, s := range servers {
+					s.saveSnapshot()
+				}
+			}
+		}
+	}()
 
 	// Setup web server
-	web.SetFechVHStatusParamsFunc(func() vhstatus.Params {
-		return vhs.Params()
-	})
-	if pathTemplateDir != "" {
-		web.SetTemplateDirPath(pathTemplateDir)
-		http.HandleFunc("/", web.Index)
+	// the first server is served by the endpoints without the server name.
+	primary := servers[0]
+	web.SetFechVHStatusParamsFunc(primary.vhs.Params)
+	web.SetFetchPlayerSessionsFunc(primary.vhs.PlayerSessions)
+	web.SetSubscribeFunc(primary.vhs.Subscribe)
+	web.SetFetchHistoryFunc(primary.vhs.History)
+	web.SetFetchWorldSizesFunc(primary.vhs.WorldSizes)
+	web.SetFetchConnectionFailuresFunc(primary.vhs.ConnectionFailures)
+	web.SetServers(webServers)
+	web.SetFetchLogStatsFunc(primary.watcher.Stats)
+	web.SetAdminToken(cfg.Auth.AdminToken)
+
+	if cfg.Features.WebUI && cfg.TemplateDir != "" {
+		web.SetTemplateDirPath(cfg.TemplateDir)
+		if len(servers) > 1 {
+			http.HandleFunc("/", web.OverviewPage)
+		} else {
+			http.HandleFunc("/", web.Index)
+		}
+		http.HandleFunc("/servers/", web.ServerPages)
+	}
+	if cfg.Features.API {
+		http.HandleFunc("/api", web.ApiGetStatus)
+		http.HandleFunc("/api/players/", web.ApiPlayers)
+		http.HandleFunc("/api/history", web.ApiHistory)
+		http.HandleFunc("/api/world-size", web.ApiWorldSize)
+		http.HandleFunc("/api/connection-failures", web.AdminOnly(web.ApiConnectionFailures))
+		http.HandleFunc("/api/servers", web.ApiServers)
+		http.HandleFunc("/api/servers/", web.ApiServers)
+	}
+	if cfg.Features.Events {
+		http.HandleFunc("/api/events", web.ApiEvents)
+	}
+	if cfg.Features.WebSocket {
+		http.HandleFunc("/ws", web.WebSocket)
+	}
+	if cfg.Features.Metrics {
+		http.HandleFunc("/metrics", web.Metrics)
 	}
-	http.HandleFunc("/api", web.ApiGetStatus)
 
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	server := &http.Server{
+		Addr: cfg.Listen,
+		// cancel the requests (e.g. event streams) on shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+
+	// Shutdown
+	wg.Wait()
+	for _, s := range servers {
+		s.saveSnapshot()
+	}
+	for _, n := range notifiers {
+		n.Close()
+	}
 }
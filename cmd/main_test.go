@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/config"
+)
+
+func parseFlagsForTest(t *testing.T, args ...string) config.Config {
+	t.Helper()
+
+	fs := flag.NewFlagSet("vhstatus-server", flag.ContinueOnError)
+	_, apply := defineFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	apply(&cfg)
+	return cfg
+}
+
+func Test_DefineFlags(t *testing.T) {
+	// the flags of the default server.
+	cfg := parseFlagsForTest(t, "-port", "9000", "-data-path", "vhstatus.json", "-source", "pipe", "-source-path", "/tmp/valheim.fifo")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Config#Validate returned %v", err)
+	}
+	servers := cfg.ServerList()
+	if cfg.Listen != ":9000" || len(servers) != 1 || servers[0].DataPath != "vhstatus.json" ||
+		servers[0].Source != (config.SourceConfig{Type: config.SourcePipe, Path: "/tmp/valheim.fifo"}) {
+		t.Errorf("flags = %q, %+v", cfg.Listen, servers)
+	}
+
+	cfg = parseFlagsForTest(t, "-server", "main=/logs/main", "-server", "hardcore=/logs/hardcore")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Config#Validate(-server) returned %v", err)
+	}
+	if servers := cfg.ServerList(); len(servers) != 2 || servers[1] != (config.ServerConfig{Name: "hardcore", LogDir: "/logs/hardcore"}) {
+		t.Errorf("-server flags = %+v", servers)
+	}
+
+	// the flags of the default server are not used with -server.
+	for _, args := range [][]string{
+		{"-data-path", "vhstatus.json"},
+		{"-source", "stdin"},
+		{"-source", "pipe", "-source-path", "/tmp/valheim.fifo"},
+	} {
+		cfg := parseFlagsForTest(t, append([]string{"-server", "main=/logs/main"}, args...)...)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Config#Validate(-server with %q) returned nil", args)
+		}
+	}
+}
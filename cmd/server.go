@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/config"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+	"github.com/mitsu-ksgr/vhstatus/internal/web"
+)
+
+// server is a Valheim server watched by vhstatus.
+type server struct {
+	name     string
+	logDir   string
+	logGlobs []string // file name patterns of the past log files.
+	vhs      *vhstatus.VHStatus
+	watcher  *vhlogwatcher.Watcher
+
+	// source is where the server output is read from,
+	// nil to read the log files in logDir.
+	source vhlogwatcher.Source
+
+	persister vhstatus.Persister // nil if the status is not persisted.
+
+	// storeMu serializes applying log events and taking snapshots,
+	// so that a snapshot always matches its checkpoint.
+	storeMu sync.Mutex
+}
+
+func newServer(name, logDir, dataPath string, handshakeTimeout time.Duration) *server {
+	s := &server{
+		name:    name,
+		logDir:  strings.TrimSuffix(logDir, "/"),
+		vhs:     vhstatus.New(),
+		watcher: vhlogwatcher.NewWatcher(),
+	}
+	// correlate the characters with the SteamIDs using the known players.
+	s.watcher.Correlator.Timeout = handshakeTimeout
+	s.watcher.Correlator.History = s.vhs
+
+	if dataPath != "" {
+		s.persister = vhstatus.NewJSONFilePersister(dataPath)
+	}
+	return s
+}
+
+// newSource returns the source of the server output,
+// or nil if it is the log files.
+func newSource(sc config.SourceConfig) vhlogwatcher.Source {
+	switch sc.Type {
+	case config.SourceStdin:
+		return vhlogwatcher.NewStreamSource("stdin", os.Stdin)
+	case config.SourcePipe:
+		return &vhlogwatcher.PipeSource{Path: sc.Path}
+	case config.SourceJournald:
+		if sc.Path == "" {
+			return vhlogwatcher.NewJournalSource("stdin", os.Stdin)
+		}
+		return &vhlogwatcher.PipeSource{Path: sc.Path, Journal: true}
+	}
+	return nil
+}
+
+func (s *server) logFile() string {
+	return s.logDir + "/vhserver-console.log"
+}
+
+func (s *server) webServer() web.Server {
+	return web.Server{
+		Name:                s.name,
+		FetchParams:         s.vhs.Params,
+		FetchPlayerSessions: s.vhs.PlayerSessions,
+		Subscribe:           s.vhs.Subscribe,
+		FetchHistory:        s.vhs.History,
+		FetchWorldSizes:     s.vhs.WorldSizes,
+
+		FetchConnectionFailures: s.vhs.ConnectionFailures,
+		FetchLogStats:           s.watcher.Stats,
+	}
+}
+
+func (s *server) log2store(event vhlogwatcher.VHLogEvent) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+
+	vhs := s.vhs
+	switch event.Event {
+	//---------------------------------------------------------------------
+	// Server Event
+	case vhlogwatcher.GameServerConnected:
+		vhs.SetStatus("Online")
+
+	case vhlogwatcher.GameServerConnectedFailed, vhlogwatcher.GameServerDisconnected:
+		vhs.SetStatus("Offile")
+		vhs.DisconnectAllPlayers(vhstatus.DisconnectReasonImplicit, event.Timestamp)
+		vhs.EndRaid(event.Timestamp)
+
+	case vhlogwatcher.ValheimVersion:
+		// the version is logged when the server starts.
+		vhs.SetValheimVersion(event.Value)
+		vhs.DisconnectAllPlayers(vhstatus.DisconnectReasonImplicit, event.Timestamp)
+		vhs.EndRaid(event.Timestamp)
+		vhs.ServerStarted()
+
+	case vhlogwatcher.ServerID:
+		vhs.SetServerID(event.Value)
+
+	case vhlogwatcher.LoadWorld:
+		vhs.SetWorldName(event.Value)
+
+	case vhlogwatcher.InitWorldGenSeed:
+		vhs.SetWorldSeed(event.Value)
+
+	case vhlogwatcher.DayHasPassed:
+		vhs.SetDay(event.Value)
+
+	case vhlogwatcher.WorldProgression:
+		vhs.SetGlobalKey(event.Value, event.Timestamp)
+
+	case vhlogwatcher.RandomEvent:
+		vhs.StartRaid(event.Value, event.Timestamp)
+
+	case vhlogwatcher.WorldSaved:
+		ms, err := strconv.ParseFloat(event.Value, 64)
+		if err != nil {
+			break
+		}
+		duration := time.Duration(ms * float64(time.Millisecond))
+		if vhs.RecordWorldSave(duration, event.Timestamp) {
+			log.Printf("%s: warning: the world save at %s took %v", s.name, event.Timestamp.Format(time.RFC3339), duration)
+		}
+
+	case vhlogwatcher.WorldSize:
+		if zdos, err := strconv.Atoi(event.Value); err == nil {
+			vhs.RecordWorldSize(zdos, event.Timestamp)
+		}
+
+	case vhlogwatcher.ModFramework:
+		vhs.SetModFramework(event.Mod + " " + event.ModVersion)
+
+	case vhlogwatcher.ModLoaded:
+		vhs.AddMod(vhstatus.Mod{Name: event.Mod, Version: event.ModVersion, LoadedAt: event.Timestamp})
+
+	case vhlogwatcher.ModError:
+		log.Printf("%s: warning: the mod %s %s has failed to load: %s", s.name, event.Mod, event.ModVersion, event.Value)
+		vhs.AddModError(vhstatus.ModError{Name: event.Mod, Version: event.ModVersion, Error: event.Value, At: event.Timestamp})
+
+	//---------------------------------------------------------------------
+	// User Event
+	case vhlogwatcher.Connection,
+		vhlogwatcher.GotHandshake,
+		vhlogwatcher.Disconnection:
+		vhs.UpdatePlayer(vhstatus.Player{
+			SteamID:   event.SteamID,
+			Platform:  event.Platform,
+			Status:    event.Event.String(),
+			UpdatedAt: event.Timestamp,
+		})
+	case vhlogwatcher.GotCharacter:
+		vhs.UpdatePlayer(vhstatus.Player{
+			SteamID:   event.SteamID,
+			Platform:  event.Platform,
+			Status:    event.Event.String(),
+			Name:      event.Name,
+			UpdatedAt: event.Timestamp,
+		})
+	case vhlogwatcher.PlayerDeath:
+		vhs.AddPlayerDeath(event.Name, event.Timestamp)
+
+	case vhlogwatcher.WrongPassword,
+		vhlogwatcher.IncompatibleVersion,
+		vhlogwatcher.Banned,
+		vhlogwatcher.Kicked:
+		vhs.AddConnectionFailure(vhstatus.ConnectionFailure{
+			SteamID: event.SteamID,
+			Name:    event.Name,
+			Reason:  event.Event.String(),
+			Detail:  event.Value,
+			At:      event.Timestamp,
+		})
+
+	default:
+		if event.Event.IsCustom() {
+			vhs.RecordCustomEvent(vhstatus.CustomEvent{
+				Name:    event.Event.String(),
+				Value:   event.Value,
+				SteamID: event.SteamID,
+				Player:  event.Name,
+				At:      event.Timestamp,
+			})
+		}
+	}
+
+	vhs.RecordHistory(event.Timestamp)
+	vhs.SetCheckpoint(vhstatus.Checkpoint{
+		File:   filepath.Base(event.Position.File),
+		Head:   event.Position.Head,
+		Offset: event.Position.Offset,
+	})
+	// the connection failures are only served by the admin endpoint,
+	// so they are neither published nor notified.
+	if event.Event.IsConnectionFailure() {
+		return
+	}
+	vhs.Publish(event)
+	notify(s.name, event, vhs.Params)
+}
+
+func (s *server) saveSnapshot() {
+	if s.persister == nil {
+		return
+	}
+
+	s.storeMu.Lock()
+	snapshot := s.vhs.Snapshot()
+	s.storeMu.Unlock()
+
+	if err := s.persister.Save(snapshot); err != nil {
+		log.Printf("%s: %v", s.name, err)
+	}
+}
+
+// restoreSnapshot restores the data store, and returns the checkpoint and
+// the time when the snapshot was saved.
+func (s *server) restoreSnapshot() (vhstatus.Checkpoint, time.Time) {
+	if s.persister == nil {
+		return vhstatus.Checkpoint{}, time.Time{}
+	}
+
+	snapshot, err := s.persister.Load()
+	if err == vhstatus.ErrNoSnapshot {
+		return vhstatus.Checkpoint{}, time.Time{}
+	} else if err != nil {
+		log.Fatalf("%s: %v", s.name, err)
+	}
+
+	s.vhs.Restore(snapshot)
+	return snapshot.Checkpoint, snapshot.SavedAt
+}
+
+// getPastLogFiles returns the past log files in chronological order.
+// The current log file is excluded even if it matches the globs.
+func (s *server) getPastLogFiles() ([]string, error) {
+	if _, err := os.Stat(s.logDir); err != nil {
+		return nil, err
+	}
+	files, err := vhlogwatcher.PastLogFiles(s.logDir, s.logGlobs)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]string, 0, len(files))
+	for _, f := range files {
+		if filepath.Clean(f) != filepath.Clean(s.logFile()) {
+			logs = append(logs, f)
+		}
+	}
+	return logs, nil
+}
+
+// readPastLogs reads the past log files from the offsets.
+// The files which have been removed (e.g. by the log rotation) and the broken
+// compressed files are skipped.
+func (s *server) readPastLogs(ctx context.Context, logpaths []string, offsets []int64) error {
+	for i, f := range logpaths {
+		if offsets[i] < 0 {
+			continue
+		}
+
+		err := s.watcher.ReadFrom(ctx, f, offsets[i], s.log2store)
+		if errors.Is(err, os.ErrNotExist) {
+			log.Printf("%s: skip the removed log file: %v", s.name, err)
+		} else if err != nil && ctx.Err() == nil && vhlogwatcher.IsCompressed(f) {
+			log.Printf("%s: skip the broken log file: %v", s.name, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchRetryInterval is the interval to retry watching the log file.
+const watchRetryInterval = time.Second * 5
+
+// catchUp reads the past log files after the position, and returns the offset
+// of the current log file to watch from (negative to watch from the end).
+// The file is identified by its head, so the file which has been rotated since
+// the position is read to the end, and the lines before the position are not
+// read again.
+func (s *server) catchUp(ctx context.Context, head string, offset int64, since time.Time) (int64, error) {
+	pastLogFiles, err := s.getPastLogFiles()
+	if err != nil {
+		return 0, err
+	}
+	logFiles := append(pastLogFiles, s.logFile())
+	offsets := vhlogwatcher.ResumeOffsets(logFiles, head, offset, since)
+	if err := s.readPastLogs(ctx, pastLogFiles, offsets); err != nil {
+		return 0, err
+	}
+	return offsets[len(offsets)-1], nil
+}
+
+// watchLog watches the log file until ctx is done.
+// If the watching fails, it retries from the last event.
+func (s *server) watchLog(ctx context.Context, offset int64) {
+	var last vhlogwatcher.Position
+	for {
+		startedAt := time.Now()
+		source := vhlogwatcher.FileSource{Path: s.logFile(), Offset: offset}
+		err := source.Watch(ctx, s.watcher, func(event vhlogwatcher.VHLogEvent) {
+			last = event.Position
+			s.log2store(event)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("%s: failed to watch the log file, retry in %v: %v", s.name, watchRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+
+		// the log file may have been rotated while it was not watched.
+		if last.Head == "" {
+			continue // no event yet, retry from the same offset.
+		}
+		if offset, err = s.catchUp(ctx, last.Head, last.Offset, startedAt); err != nil && ctx.Err() == nil {
+			log.Printf("%s: failed to read the past log files: %v", s.name, err)
+			offset = last.Offset
+		}
+	}
+}
+
+// watchSource reads the server output from the source until ctx is done or
+// the output ends. If the reading fails, it retries unless the source is a
+// stream.
+//
+// A source has no checkpoint, and may replay the past output (e.g. `docker
+// logs --since` or the last entries of `journalctl -f`), so the events until
+// since (the time of the restored snapshot) are skipped.
+func (s *server) watchSource(ctx context.Context, since time.Time) {
+	callback := func(event vhlogwatcher.VHLogEvent) {
+		if !event.Timestamp.After(since) {
+			return
+		}
+		s.log2store(event)
+	}
+	for {
+		err := s.source.Watch(ctx, s.watcher, callback)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			log.Printf("%s: the output from %v has ended", s.name, s.source)
+			return
+		}
+		// the reader of a stream is left to the failed Watch, and the stream
+		// can not be read again without losing the lines buffered by it.
+		if _, ok := s.source.(*vhlogwatcher.StreamSource); ok {
+			log.Printf("%s: failed to read %v: %v", s.name, s.source, err)
+			return
+		}
+
+		log.Printf("%s: failed to read %v, retry in %v: %v", s.name, s.source, watchRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// start restores the status, reads the past logs, and then starts watching
+// the log file until ctx is done.
+// If the server has a source, it reads the source instead of the log files.
+func (s *server) start(ctx context.Context, wg *sync.WaitGroup) error {
+	checkpoint, savedAt := s.restoreSnapshot()
+	if s.source != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watchSource(ctx, savedAt)
+		}()
+		return nil
+	}
+
+	offset, err := s.catchUp(ctx, checkpoint.Head, checkpoint.Offset, savedAt)
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.watchLog(ctx, offset)
+	}()
+	return nil
+}
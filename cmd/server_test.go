@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/notifier"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+type recordNotifier struct {
+	events []vhlogwatcher.EventType
+}
+
+func (n *recordNotifier) Notify(server string, event vhlogwatcher.VHLogEvent, params vhstatus.Params) {
+	n.events = append(n.events, event.Event)
+}
+
+func (n *recordNotifier) Close() {}
+
+func Test_Log2Store_ConnectionFailure(t *testing.T) {
+	rec := &recordNotifier{}
+	notifiers = []notifier.Notifier{rec}
+	t.Cleanup(func() { notifiers = nil })
+
+	s := newServer("default", t.TempDir(), "", time.Minute)
+	ch, unsubscribe := s.vhs.Subscribe()
+	defer unsubscribe()
+
+	now := time.Now()
+	s.log2store(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.WrongPassword, SteamID: "76561198000000001", Timestamp: now})
+	s.log2store(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerConnected, Timestamp: now})
+
+	if len(rec.events) != 1 || rec.events[0] != vhlogwatcher.GameServerConnected {
+		t.Errorf("notified %v, want only GameServerConnected", rec.events)
+	}
+	if got := s.vhs.ConnectionFailures(""); len(got["76561198000000001"]) != 1 {
+		t.Errorf("ConnectionFailures = %+v, want the wrong password", got)
+	}
+	for len(ch) > 0 {
+		if n := <-ch; n.Event != nil && n.Event.(vhlogwatcher.VHLogEvent).Event.IsConnectionFailure() {
+			t.Errorf("published %+v", n.Event)
+		}
+	}
+}
+
+func Test_WatchSource_Replay(t *testing.T) {
+	dataPath := filepath.Join(t.TempDir(), "vhstatus.json")
+	logLine := func(at time.Time, text string) string {
+		return at.Format("01/02/2006 15:04:05") + ": " + text + "\n"
+	}
+	watch := func(output string) *server {
+		t.Helper()
+		s := newServer("default", "", dataPath, time.Minute)
+		s.source = vhlogwatcher.NewStreamSource("stdin", strings.NewReader(output))
+
+		var wg sync.WaitGroup
+		if err := s.start(context.Background(), &wg); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		s.saveSnapshot()
+		return s
+	}
+
+	old := time.Now().Add(-time.Minute)
+	watch(logLine(old, "World saved ( 10ms )") + logLine(old, "World saved ( 20ms )"))
+
+	// the writer is restarted, and replays the output before the snapshot.
+	s := watch(logLine(old, "World saved ( 10ms )") + logLine(old, "World saved ( 20ms )") +
+		logLine(time.Now().Add(time.Minute), "World saved ( 30ms )"))
+	if got := s.vhs.Params().WorldSave.Count; got != 3 {
+		t.Errorf("WorldSave.Count = %d, want 3", got)
+	}
+}
+
+// failingReader fails the first read, and then has a line.
+type failingReader struct {
+	reads int
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	r.reads++
+	if r.reads == 1 {
+		return 0, errors.New("read failed")
+	}
+	return copy(p, "04/10/2021 12:00:00: Game server connected\n"), nil
+}
+
+func Test_WatchSource_StreamError(t *testing.T) {
+	s := newServer("default", "", "", time.Minute)
+	r := &failingReader{}
+	s.source = vhlogwatcher.NewStreamSource("stdin", r)
+
+	done := make(chan struct{})
+	go func() {
+		s.watchSource(context.Background(), time.Time{})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(watchRetryInterval / 2):
+		t.Fatal("watchSource did not stop after the stream has failed")
+	}
+	if r.reads != 1 {
+		t.Errorf("the stream was read %d times after the failure", r.reads-1)
+	}
+}
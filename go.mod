@@ -3,9 +3,8 @@ module github.com/mitsu-ksgr/vhstatus
 go 1.16
 
 require (
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
-	github.com/hpcloud/tail v1.0.0
-	golang.org/x/sys v0.0.0-20210326220804-49726bf1d181 // indirect
-	gopkg.in/fsnotify.v1 v1.4.7 // indirect
-	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
+	github.com/BurntSushi/toml v0.3.1
+	github.com/gorilla/websocket v1.4.2
+	github.com/klauspost/compress v1.15.9
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,311 @@
+// Package config loads the configuration of vhstatus.
+//
+// The configuration is merged in the following order, the later one wins:
+//
+//  1. the default values.
+//  2. the config file (TOML, YAML or JSON, chosen by the extension).
+//  3. the environment variables, VHSTATUS_ + the key in upper case,
+//     e.g. VHSTATUS_DISCORD_MIN_INTERVAL for "discord.min_interval".
+//  4. the command line flags (applied by the caller).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Duration is time.Duration which is written as a string, e.g. "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type Config struct {
+	Listen           string   `json:"listen"`       // address of the http server, e.g. ":8000".
+	LogDir           string   `json:"log_dir"`      // directory of vhserver-console.log.
+	TemplateDir      string   `json:"template_dir"` // directory of the html templates.
+	HandshakeTimeout Duration `json:"handshake_timeout"`
+	RulesFile        string   `json:"rules_file"` // JSON file of the additional log patterns.
+
+	// LogGlobs are the file name patterns of the past log files in the log
+	// directories. Their compressed files (.gz or .zst) are read as well.
+	LogGlobs []string `json:"log_globs"`
+
+	// Source is where the output of the server is read from, instead of the
+	// log files in log_dir.
+	Source SourceConfig `json:"source"`
+
+	// Servers are the Valheim servers to watch.
+	// If empty, the server of log_dir and data.path is watched as "default".
+	Servers []ServerConfig `json:"servers"`
+
+	Data      DataConfig      `json:"data"`
+	WorldSave WorldSaveConfig `json:"world_save"`
+	Discord   DiscordConfig   `json:"discord"`
+	Webhook   WebhookConfig   `json:"webhook"`
+	Auth      AuthConfig      `json:"auth"`
+	Features  FeaturesConfig  `json:"features"`
+}
+
+// ServerConfig is the configuration of a Valheim server.
+type ServerConfig struct {
+	Name     string       `json:"name"`      // used in the URLs, e.g. /servers/{name}/
+	LogDir   string       `json:"log_dir"`   // directory of vhserver-console.log.
+	DataPath string       `json:"data_path"` // file to persist the status, disabled if empty.
+	Source   SourceConfig `json:"source"`
+}
+
+// The types of the source of the server output.
+const (
+	SourceFile     = "file"     // vhserver-console.log in the log directory.
+	SourceStdin    = "stdin"    // e.g. `docker logs -f valheim | vhstatus-server -source stdin`
+	SourcePipe     = "pipe"     // named pipe (FIFO) at the path.
+	SourceJournald = "journald" // `journalctl -o export` from the path, or stdin if empty.
+)
+
+// SourceConfig is the source of the server output.
+type SourceConfig struct {
+	Type string `json:"type"` // one of Source*, "file" if empty.
+	Path string `json:"path"`
+}
+
+// isFile reports whether the source is the log files in the log directory.
+func (s SourceConfig) isFile() bool {
+	return s.Type == "" || s.Type == SourceFile
+}
+
+// readsStdin reports whether the source reads stdin.
+func (s SourceConfig) readsStdin() bool {
+	return s.Type == SourceStdin || (s.Type == SourceJournald && s.Path == "")
+}
+
+func (s SourceConfig) validate(key string) error {
+	switch s.Type {
+	case "", SourceFile, SourceStdin, SourceJournald:
+	case SourcePipe:
+		if s.Path == "" {
+			return &KeyError{Key: key + ".path", Err: errors.New("must not be empty for a pipe")}
+		}
+	default:
+		return &KeyError{Key: key + ".type", Err: fmt.Errorf("unknown source %q", s.Type)}
+	}
+	return nil
+}
+
+// DefaultServerName is the name of the server when no servers are configured.
+const DefaultServerName = "default"
+
+// ServerList returns the servers to watch.
+func (c Config) ServerList() []ServerConfig {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+	return []ServerConfig{{Name: DefaultServerName, LogDir: c.LogDir, DataPath: c.Data.Path, Source: c.Source}}
+}
+
+// DataConfig is the configuration to persist the status.
+type DataConfig struct {
+	Path         string   `json:"path"` // disabled if empty.
+	SaveInterval Duration `json:"save_interval"`
+}
+
+// WorldSaveConfig is the configuration to monitor the world saves.
+type WorldSaveConfig struct {
+	// SlowThreshold is the duration of a save to warn, disabled if zero.
+	SlowThreshold Duration `json:"slow_threshold"`
+}
+
+type DiscordConfig struct {
+	Webhooks    []string `json:"webhooks"`
+	Username    string   `json:"username"`
+	EmbedsFile  string   `json:"embeds_file"` // JSON file of the embed messages.
+	MinInterval Duration `json:"min_interval"`
+}
+
+type WebhookConfig struct {
+	TargetsFile string `json:"targets_file"` // JSON file of the outgoing webhook targets.
+}
+
+type AuthConfig struct {
+	// AdminToken is the bearer token to access the admin endpoints.
+	// The admin endpoints are disabled if empty.
+	AdminToken string `json:"admin_token"`
+}
+
+// FeaturesConfig toggles the endpoints of the http server.
+type FeaturesConfig struct {
+	WebUI     bool `json:"web_ui"`    // "/", also requires template_dir.
+	API       bool `json:"api"`       // "/api", "/api/players/"
+	Events    bool `json:"events"`    // "/api/events"
+	WebSocket bool `json:"websocket"` // "/ws"
+	Metrics   bool `json:"metrics"`   // "/metrics"
+}
+
+// Default returns the default configuration.
+func Default() Config {
+	return Config{
+		Listen:           ":8000",
+		LogDir:           "/home/vhserver/log/console/",
+		HandshakeTimeout: Duration(time.Minute * 5),
+		LogGlobs:         []string{"vhserver-console-*.log"},
+		Source:           SourceConfig{Type: SourceFile},
+		Data: DataConfig{
+			SaveInterval: Duration(time.Minute),
+		},
+		WorldSave: WorldSaveConfig{
+			SlowThreshold: Duration(time.Second * 5),
+		},
+		Discord: DiscordConfig{
+			MinInterval: Duration(time.Second * 2),
+		},
+		Features: FeaturesConfig{
+			WebUI:     true,
+			API:       true,
+			Events:    true,
+			WebSocket: true,
+			Metrics:   true,
+		},
+	}
+}
+
+// KeyError is an error of a key of the configuration.
+type KeyError struct {
+	Source string // the config file or the environment variable.
+	Key    string
+	Err    error
+}
+
+func (e *KeyError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Source, e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// Load returns the default configuration overridden by the config file and
+// the environment variables. If path is empty, the config file is not read.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := loadEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+func getenv(key, default_value string) string {
+	value := os.Getenv(key)
+	if len(value) == 0 {
+		return default_value
+	}
+	return value
+}
+
+// Validate checks the values of the configuration.
+func (c Config) Validate() error {
+	if c.Listen == "" {
+		return &KeyError{Key: "listen", Err: errors.New("must not be empty")}
+	}
+	if c.LogDir == "" {
+		return &KeyError{Key: "log_dir", Err: errors.New("must not be empty")}
+	}
+	for i, glob := range c.LogGlobs {
+		if _, err := filepath.Match(glob, ""); glob == "" || err != nil {
+			return &KeyError{Key: fmt.Sprintf("log_globs[%d]", i), Err: fmt.Errorf("invalid pattern %q", glob)}
+		}
+	}
+	if c.HandshakeTimeout <= 0 {
+		return &KeyError{Key: "handshake_timeout", Err: errors.New("must be positive")}
+	}
+	if c.Data.SaveInterval <= 0 {
+		return &KeyError{Key: "data.save_interval", Err: errors.New("must be positive")}
+	}
+	if c.WorldSave.SlowThreshold < 0 {
+		return &KeyError{Key: "world_save.slow_threshold", Err: errors.New("must not be negative")}
+	}
+	if c.Discord.MinInterval < 0 {
+		return &KeyError{Key: "discord.min_interval", Err: errors.New("must not be negative")}
+	}
+	if err := c.Source.validate("source"); err != nil {
+		return err
+	}
+	// data.path and source are of the default server, which is not watched
+	// with servers. Each server has its own data_path and source.
+	if len(c.Servers) > 0 && c.Data.Path != "" {
+		return &KeyError{Key: "data.path", Err: errors.New("is not used with servers, set data_path of each server")}
+	}
+	if len(c.Servers) > 0 && (!c.Source.isFile() || c.Source.Path != "") {
+		return &KeyError{Key: "source", Err: errors.New("is not used with servers, set source of each server")}
+	}
+	names := make(map[string]bool)
+	dataPaths := make(map[string]bool)
+	stdin := false
+	for i, srv := range c.Servers {
+		key := fmt.Sprintf("servers[%d]", i)
+		if err := srv.Source.validate(key + ".source"); err != nil {
+			return err
+		}
+		if srv.Source.readsStdin() {
+			if stdin {
+				return &KeyError{Key: key + ".source", Err: errors.New("stdin is read by another server")}
+			}
+			stdin = true
+		}
+		switch {
+		case srv.Name == "":
+			return &KeyError{Key: key + ".name", Err: errors.New("must not be empty")}
+		case !reServerName.MatchString(srv.Name):
+			return &KeyError{Key: key + ".name", Err: fmt.Errorf("%q must consist of letters, digits, '-' and '_'", srv.Name)}
+		case names[srv.Name]:
+			return &KeyError{Key: key + ".name", Err: fmt.Errorf("duplicated name %q", srv.Name)}
+		case srv.LogDir == "" && srv.Source.isFile():
+			return &KeyError{Key: key + ".log_dir", Err: errors.New("must not be empty")}
+		case srv.DataPath != "" && dataPaths[srv.DataPath]:
+			return &KeyError{Key: key + ".data_path", Err: fmt.Errorf("%q is used by another server", srv.DataPath)}
+		}
+		names[srv.Name] = true
+		dataPaths[srv.DataPath] = true
+	}
+	for i, u := range c.Discord.Webhooks {
+		if err := validateURL(u); err != nil {
+			return &KeyError{Key: fmt.Sprintf("discord.webhooks[%d]", i), Err: err}
+		}
+	}
+	return nil
+}
+
+var reServerName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", s)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setenv sets the environment variable until the test ends.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func Test_Load_Default(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load(\"\") returned %v", err)
+	}
+	if cfg.Listen != Default().Listen || !cfg.Features.Metrics {
+		t.Errorf("Load(\"\") = %+v, want the default config", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Default().Validate() returned %v", err)
+	}
+}
+
+func Test_Load_Formats(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"vhstatus.toml", `
+listen = ":9000"
+log_dir = "/var/log/valheim"
+
+[discord]
+webhooks = ["https://discord.test/1", "https://discord.test/2"]
+min_interval = "5s"
+
+[features]
+websocket = false
+`},
+		{"vhstatus.yaml", `
+listen: ":9000"
+log_dir: /var/log/valheim
+discord:
+  webhooks:
+    - https://discord.test/1
+    - https://discord.test/2
+  min_interval: 5s
+features:
+  websocket: false
+`},
+		{"vhstatus.json", `{
+  "listen": ":9000",
+  "log_dir": "/var/log/valheim",
+  "discord": {
+    "webhooks": ["https://discord.test/1", "https://discord.test/2"],
+    "min_interval": "5s"
+  },
+  "features": {"websocket": false}
+}`},
+	}
+
+	for _, c := range cases {
+		cfg, err := Load(writeConfig(t, c.name, c.content))
+		if err != nil {
+			t.Errorf("Load(%s) returned %v", c.name, err)
+			continue
+		}
+
+		if cfg.Listen != ":9000" || cfg.LogDir != "/var/log/valheim" {
+			t.Errorf("Load(%s) = %+v, want listen and log_dir overridden", c.name, cfg)
+		}
+		if strings.Join(cfg.Discord.Webhooks, ",") != "https://discord.test/1,https://discord.test/2" {
+			t.Errorf("Load(%s).Discord.Webhooks = %v", c.name, cfg.Discord.Webhooks)
+		}
+		if cfg.Discord.MinInterval != Duration(time.Second*5) {
+			t.Errorf("Load(%s).Discord.MinInterval = %v, want 5s", c.name, time.Duration(cfg.Discord.MinInterval))
+		}
+		if cfg.Features.WebSocket || !cfg.Features.API {
+			t.Errorf("Load(%s).Features = %+v, want only websocket disabled", c.name, cfg.Features)
+		}
+		// not in the file.
+		if cfg.Data.SaveInterval != Default().Data.SaveInterval {
+			t.Errorf("Load(%s).Data.SaveInterval = %v, want the default", c.name, time.Duration(cfg.Data.SaveInterval))
+		}
+	}
+}
+
+func Test_Load_Env(t *testing.T) {
+	path := writeConfig(t, "vhstatus.yaml", "listen: \":9000\"\ndata:\n  path: /from/file\n")
+	setenv(t, "VHSTATUS_LISTEN", ":9100")
+	setenv(t, "VHSTATUS_DISCORD_WEBHOOKS", "https://discord.test/1, https://discord.test/2")
+	setenv(t, "VHSTATUS_FEATURES_METRICS", "false")
+	setenv(t, "VHSTATUS_AUTH_ADMIN_TOKEN", "secret")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	if cfg.Listen != ":9100" {
+		t.Errorf("Load().Listen = %q, want the environment variable to win", cfg.Listen)
+	}
+	if cfg.Data.Path != "/from/file" {
+		t.Errorf("Load().Data.Path = %q, want %q", cfg.Data.Path, "/from/file")
+	}
+	if len(cfg.Discord.Webhooks) != 2 || cfg.Discord.Webhooks[1] != "https://discord.test/2" {
+		t.Errorf("Load().Discord.Webhooks = %q", cfg.Discord.Webhooks)
+	}
+	if cfg.Features.Metrics {
+		t.Error("Load().Features.Metrics = true, want false")
+	}
+	if cfg.Auth.AdminToken != "secret" {
+		t.Errorf("Load().Auth.AdminToken = %q, want %q", cfg.Auth.AdminToken, "secret")
+	}
+}
+
+func Test_Load_Error(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		env     map[string]string
+		wantKey string
+	}{
+		{"unknown.toml", "[discord]\nusernme = \"bot\"\n", nil, "discord.usernme"},
+		{"type.yaml", "features:\n  api: maybe\n", nil, "features.api"},
+		{"duration.json", `{"data": {"save_interval": "soon"}}`, nil, "data.save_interval"},
+		{"section.json", `{"discord": "https://discord.test/1"}`, nil, "discord"},
+		{"servers.yaml", "servers:\n  - name: a\n    logdir: /logs\n", nil, "servers"},
+		{"env.json", `{}`, map[string]string{"VHSTATUS_FEATURES_EVENTS": "nope"}, "features.events"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				setenv(t, k, v)
+			}
+
+			_, err := Load(writeConfig(t, c.name, c.content))
+			var ke *KeyError
+			if !errors.As(err, &ke) {
+				t.Fatalf("Load(%s) returned %v, want KeyError", c.name, err)
+			}
+			if ke.Key != c.wantKey {
+				t.Errorf("Load(%s) returned the error of %q, want %q", c.name, ke.Key, c.wantKey)
+			}
+			if !strings.Contains(err.Error(), c.wantKey) {
+				t.Errorf("Load(%s) error %q does not contain the key", c.name, err.Error())
+			}
+		})
+	}
+
+	if _, err := Load(writeConfig(t, "vhstatus.ini", "")); err == nil {
+		t.Error("Load(.ini) did not return an error")
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	cases := []struct {
+		modify  func(*Config)
+		wantKey string
+	}{
+		{func(c *Config) { c.Listen = "" }, "listen"},
+		{func(c *Config) { c.LogGlobs = []string{"vhserver-console-*.log", "[a-"} }, "log_globs[1]"},
+		{func(c *Config) { c.Data.SaveInterval = 0 }, "data.save_interval"},
+		{func(c *Config) { c.WorldSave.SlowThreshold = -1 }, "world_save.slow_threshold"},
+		{func(c *Config) { c.Discord.Webhooks = []string{"https://discord.test/1", "discord"} }, "discord.webhooks[1]"},
+		{func(c *Config) { c.Servers = []ServerConfig{{Name: "a/b", LogDir: "/logs"}} }, "servers[0].name"},
+		{func(c *Config) { c.Servers = []ServerConfig{{Name: "a", LogDir: "/a"}, {Name: "a", LogDir: "/b"}} }, "servers[1].name"},
+		{func(c *Config) { c.Servers = []ServerConfig{{Name: "a", LogDir: "/a"}, {Name: "b"}} }, "servers[1].log_dir"},
+		{func(c *Config) {
+			c.Servers = []ServerConfig{{Name: "a", LogDir: "/a", DataPath: "x.json"}, {Name: "b", LogDir: "/b", DataPath: "x.json"}}
+		}, "servers[1].data_path"},
+		{func(c *Config) { c.Source = SourceConfig{Type: "docker"} }, "source.type"},
+		{func(c *Config) { c.Source = SourceConfig{Type: SourcePipe} }, "source.path"},
+		{func(c *Config) {
+			c.Servers = []ServerConfig{{Name: "a", Source: SourceConfig{Type: SourceStdin}}, {Name: "b", Source: SourceConfig{Type: SourceJournald}}}
+		}, "servers[1].source"},
+		{func(c *Config) { c.Servers, c.Data.Path = []ServerConfig{{Name: "a", LogDir: "/a"}}, "x.json" }, "data.path"},
+		{func(c *Config) { c.Servers, c.Source.Type = []ServerConfig{{Name: "a", LogDir: "/a"}}, SourceStdin }, "source"},
+	}
+
+	for i, c := range cases {
+		cfg := Default()
+		c.modify(&cfg)
+
+		var ke *KeyError
+		if err := cfg.Validate(); !errors.As(err, &ke) || ke.Key != c.wantKey {
+			t.Errorf("Config#Validate(case[%d]) returned %v, want the error of %q", i, err, c.wantKey)
+		}
+	}
+
+	// a server which does not read the log files needs no log_dir.
+	cfg := Default()
+	cfg.Servers = []ServerConfig{{Name: "a", Source: SourceConfig{Type: SourceStdin}}, {Name: "b", Source: SourceConfig{Type: SourceJournald, Path: "/run/valheim.fifo"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Config#Validate(stdin and journald from a pipe) returned %v", err)
+	}
+}
+
+func Test_ServerList(t *testing.T) {
+	cfg := Default()
+	cfg.LogDir = "/logs"
+	cfg.Data.Path = "data.json"
+	if got := cfg.ServerList(); len(got) != 1 || got[0] != (ServerConfig{DefaultServerName, "/logs", "data.json", SourceConfig{Type: SourceFile}}) {
+		t.Errorf("Config#ServerList(no servers) = %+v", got)
+	}
+
+	cfg.Servers = []ServerConfig{{Name: "a", LogDir: "/a"}, {Name: "b", LogDir: "/b"}}
+	if got := cfg.ServerList(); len(got) != 2 || got[1].Name != "b" {
+		t.Errorf("Config#ServerList() = %+v", got)
+	}
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables.
+const EnvPrefix = "VHSTATUS_"
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// loadFile overrides cfg by the config file.
+func loadFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		err = toml.Unmarshal(data, &m)
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		if err = yaml.Unmarshal(data, &raw); err == nil {
+			m = normalizeYAML(raw).(map[string]interface{})
+		}
+	case ".json":
+		err = json.Unmarshal(data, &m)
+	default:
+		return fmt.Errorf("%s: unsupported config format %q, use .toml, .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := decode(m, reflect.ValueOf(cfg).Elem(), ""); err != nil {
+		var ke *KeyError
+		if errors.As(err, &ke) {
+			ke.Source = path
+		}
+		return err
+	}
+	return nil
+}
+
+// normalizeYAML converts the maps decoded by yaml to map[string]interface{}.
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalizeYAML(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalizeYAML(e)
+		}
+	}
+	return v
+}
+
+// fieldsByKey returns the fields of the struct keyed by their JSON names.
+func fieldsByKey(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// isSection returns true if the type is a section of the config, not a value.
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// decode sets the values of m to the struct v.
+// The errors are reported with the key, e.g. "discord.min_interval".
+func decode(m map[string]interface{}, v reflect.Value, prefix string) error {
+	fields := fieldsByKey(v.Type())
+
+	// sort the keys to report the same error every time.
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := m[key]
+		fullKey := joinKey(prefix, key)
+		i, ok := fields[key]
+		if !ok {
+			return &KeyError{Key: fullKey, Err: errors.New("unknown key")}
+		}
+
+		field := v.Field(i)
+		if isSection(field.Type()) {
+			sub, ok := value.(map[string]interface{})
+			if !ok {
+				return &KeyError{Key: fullKey, Err: fmt.Errorf("must be a table, got %T", value)}
+			}
+			if err := decode(sub, field, fullKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// reuse the JSON decoder to convert the value to the field type.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return &KeyError{Key: fullKey, Err: err}
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(field.Addr().Interface()); err != nil {
+			var te *json.UnmarshalTypeError
+			if errors.As(err, &te) {
+				err = fmt.Errorf("must be %s, got %s", field.Type(), te.Value)
+			}
+			return &KeyError{Key: fullKey, Err: err}
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable name of the key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+// loadEnv overrides cfg by the environment variables.
+func loadEnv(cfg *Config) error {
+	return decodeEnv(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func decodeEnv(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		key := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		fullKey := joinKey(prefix, key)
+		field := v.Field(i)
+		if isSection(field.Type()) {
+			if err := decodeEnv(field, fullKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := EnvName(fullKey)
+		value := getenv(name, "")
+		if value == "" {
+			continue
+		}
+		if err := setString(field, value); err != nil {
+			return &KeyError{Source: name, Key: fullKey, Err: err}
+		}
+	}
+	return nil
+}
+
+// setString sets the string representation of the value to the field.
+// A list is written as comma separated values.
+func setString(field reflect.Value, value string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean, got %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		var list []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// DiscordEmbed is the template of an embed message.
+// Title and Description are text/template rendered with Data.
+type DiscordEmbed struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+}
+
+// DefaultDiscordEmbeds are the embed messages sent by default.
+var DefaultDiscordEmbeds = map[vhlogwatcher.EventType]DiscordEmbed{
+	vhlogwatcher.GotCharacter: {
+		Title:       "{{ .Player.Name }} joined the game",
+		Description: "{{ .Params.ActivePlayerCount }} player(s) online",
+		Color:       0x2ecc71,
+	},
+	vhlogwatcher.Disconnection: {
+		Title:       "{{ .Player.Name }} left the game",
+		Description: "{{ .Params.ActivePlayerCount }} player(s) online",
+		Color:       0x95a5a6,
+	},
+	vhlogwatcher.PlayerDeath: {
+		Title:       "{{ .Event.Name }} died",
+		Description: "{{ .Event.Name }} has died {{ .Player.Deaths }} time(s)",
+		Color:       0xe74c3c,
+	},
+	vhlogwatcher.RandomEvent: {
+		Title:       "Raid: {{ or .Raid.Title .Event.Value }}",
+		Description: "{{ .Params.WorldName }} is under attack, {{ .Params.ActivePlayerCount }} player(s) online",
+		Color:       0xc0392b,
+	},
+	vhlogwatcher.ModError: {
+		Title:       "Mod failed to load: {{ .Event.Mod }} {{ .Event.ModVersion }}",
+		Description: "{{ .Event.Value }}",
+		Color:       0xf1c40f,
+	},
+	vhlogwatcher.GameServerConnected: {
+		Title:       "Server is online",
+		Description: "{{ .Params.WorldName }}",
+		Color:       0x3498db,
+	},
+	vhlogwatcher.GameServerDisconnected: {
+		Title:       "Server is offline",
+		Description: "{{ .Params.WorldName }}",
+		Color:       0xe67e22,
+	},
+}
+
+type DiscordConfig struct {
+	WebhookURLs []string
+	Username    string // overrides the name of the webhook, if not empty.
+
+	// Embeds overrides DefaultDiscordEmbeds, keyed by the event name
+	// (see vhlogwatcher.ParseEventType).
+	Embeds map[string]DiscordEmbed
+
+	// ShowServer adds the server name to the footer of the embeds, so that
+	// the servers posting to the same channel can be told apart.
+	ShowServer bool
+
+	Sender SenderConfig
+}
+
+type discordTemplate struct {
+	title       *template.Template
+	description *template.Template
+	color       int
+}
+
+// Discord posts embed messages to Discord webhooks.
+type Discord struct {
+	username   string
+	showServer bool
+	templates  map[vhlogwatcher.EventType]discordTemplate
+	senders    []*sender
+	urls       []string
+}
+
+func NewDiscord(cfg DiscordConfig) (*Discord, error) {
+	embeds := make(map[vhlogwatcher.EventType]DiscordEmbed)
+	for et, embed := range DefaultDiscordEmbeds {
+		embeds[et] = embed
+	}
+	for name, embed := range cfg.Embeds {
+		et, ok := vhlogwatcher.ParseEventType(name)
+		if !ok {
+			return nil, fmt.Errorf("discord: unknown event %q", name)
+		}
+		embeds[et] = embed
+	}
+
+	d := &Discord{
+		username:   cfg.Username,
+		showServer: cfg.ShowServer,
+		templates:  make(map[vhlogwatcher.EventType]discordTemplate),
+		urls:       cfg.WebhookURLs,
+	}
+	for et, embed := range embeds {
+		title, err := parseTemplate("title", embed.Title)
+		if err != nil {
+			return nil, fmt.Errorf("discord: embed of %q: %w", et.String(), err)
+		}
+		description, err := parseTemplate("description", embed.Description)
+		if err != nil {
+			return nil, fmt.Errorf("discord: embed of %q: %w", et.String(), err)
+		}
+		d.templates[et] = discordTemplate{title: title, description: description, color: embed.Color}
+	}
+
+	for range cfg.WebhookURLs {
+		d.senders = append(d.senders, newSender(cfg.Sender))
+	}
+	return d, nil
+}
+
+type discordEmbedPayload struct {
+	Title       string                     `json:"title,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Color       int                        `json:"color,omitempty"`
+	Timestamp   string                     `json:"timestamp,omitempty"`
+	Footer      *discordEmbedFooterPayload `json:"footer,omitempty"`
+}
+
+type discordEmbedFooterPayload struct {
+	Text string `json:"text"`
+}
+
+type discordPayload struct {
+	Username string                `json:"username,omitempty"`
+	Embeds   []discordEmbedPayload `json:"embeds"`
+}
+
+func (d *Discord) Notify(server string, event vhlogwatcher.VHLogEvent, params vhstatus.Params) {
+	tmpl, ok := d.templates[event.Event]
+	if !ok {
+		return
+	}
+
+	data := NewData(server, event, params)
+	if (event.Event.IsUserEvent() || event.Event.IsConnectionFailure()) && data.Player.Name == "" {
+		// the player has not logged in with a character.
+		return
+	}
+
+	embed := discordEmbedPayload{Color: tmpl.color}
+	if !event.Timestamp.IsZero() {
+		embed.Timestamp = event.Timestamp.Format(time.RFC3339)
+	}
+	if d.showServer && server != "" {
+		embed.Footer = &discordEmbedFooterPayload{Text: server}
+	}
+	var err error
+	if embed.Title, err = render(tmpl.title, data); err != nil {
+		log.Printf("discord: %v", err)
+		return
+	}
+	if embed.Description, err = render(tmpl.description, data); err != nil {
+		log.Printf("discord: %v", err)
+		return
+	}
+
+	body, err := json.Marshal(discordPayload{
+		Username: d.username,
+		Embeds:   []discordEmbedPayload{embed},
+	})
+	if err != nil {
+		log.Printf("discord: %v", err)
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	for i, s := range d.senders {
+		s.enqueue(request{method: http.MethodPost, url: d.urls[i], header: header, body: body})
+	}
+}
+
+func (d *Discord) Close() {
+	for _, s := range d.senders {
+		s.close()
+	}
+}
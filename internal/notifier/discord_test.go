@@ -0,0 +1,314 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// webhookStandIn is a local HTTP stand-in of the webhook endpoint.
+// It replies with the status codes in order, and then 204.
+type webhookStandIn struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	bodies   [][]byte
+	methods  []string
+	headers  []http.Header
+	received []time.Time
+}
+
+func newWebhookStandIn(t *testing.T, codes ...int) *webhookStandIn {
+	w := &webhookStandIn{codes: codes}
+	w.server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.bodies = append(w.bodies, body)
+		w.methods = append(w.methods, r.Method)
+		w.headers = append(w.headers, r.Header)
+		w.received = append(w.received, time.Now())
+
+		code := http.StatusNoContent
+		if len(w.codes) > 0 {
+			code, w.codes = w.codes[0], w.codes[1:]
+		}
+		if code == http.StatusTooManyRequests {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(code)
+			rw.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.01}`))
+			return
+		}
+		rw.WriteHeader(code)
+	}))
+	t.Cleanup(w.server.Close)
+	return w
+}
+
+func (w *webhookStandIn) requests() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bodies
+}
+
+func testParams() vhstatus.Params {
+	return vhstatus.Params{
+		WorldName:         "test-world",
+		ActivePlayerCount: 1,
+		Players: []vhstatus.Player{
+			{SteamID: "1", Name: "player1", Status: "Got Character", Deaths: 2},
+			{SteamID: "2", Status: "Connection"},
+		},
+		RecentRaids: []vhstatus.Raid{
+			{Name: "army_eikthyr", Title: "Eikthyr rallies the creatures of the forest", StartedAt: time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)},
+		},
+	}
+}
+
+func testSenderConfig() SenderConfig {
+	return SenderConfig{MaxRetries: 3, RetryBaseDelay: time.Millisecond}
+}
+
+func Test_Discord_Notify(t *testing.T) {
+	standIn := newWebhookStandIn(t)
+	d, err := NewDiscord(DiscordConfig{
+		WebhookURLs: []string{standIn.server.URL},
+		Username:    "vhstatus",
+		Sender:      testSenderConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := testParams()
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GotCharacter, SteamID: "1", Name: "player1"}, params)
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.PlayerDeath, Name: "player1"}, params)
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.Disconnection, SteamID: "2"}, params) // no character
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "2"}, params)    // not supported
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerConnected}, params)
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.RandomEvent, Value: "army_eikthyr", Timestamp: params.RecentRaids[0].StartedAt}, params)
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.RandomEvent, Value: "army_unknown"}, params)
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.ModError, Mod: "Epic Loot", ModVersion: "0.9.35", Value: "it has missing dependencies"}, params)
+	d.Close()
+
+	want := []string{
+		"player1 joined the game",
+		"player1 has died 2 time(s)",
+		"Server is online",
+		"Raid: Eikthyr rallies the creatures of the forest",
+		"Raid: army_unknown",
+		"Mod failed to load: Epic Loot 0.9.35",
+	}
+	got := standIn.requests()
+	if len(got) != len(want) {
+		t.Fatalf("Discord sent %d requests, want %d: %q", len(got), len(want), got)
+	}
+	for i, w := range want {
+		var payload discordPayload
+		if err := json.Unmarshal(got[i], &payload); err != nil {
+			t.Fatalf("Discord sent invalid JSON: %v", err)
+		}
+		if payload.Username != "vhstatus" {
+			t.Errorf("Discord requests[%d].username = %q, want vhstatus", i, payload.Username)
+		}
+		if len(payload.Embeds) != 1 || !strings.Contains(payload.Embeds[0].Title+payload.Embeds[0].Description, w) {
+			t.Errorf("Discord requests[%d] = %s, want to contain %q", i, got[i], w)
+		}
+		if payload.Embeds[0].Footer != nil {
+			t.Errorf("Discord requests[%d] = %s, want no server without ShowServer", i, got[i])
+		}
+	}
+}
+
+func Test_Discord_CustomEmbeds(t *testing.T) {
+	standIn := newWebhookStandIn(t)
+	d, err := NewDiscord(DiscordConfig{
+		WebhookURLs: []string{standIn.server.URL, standIn.server.URL},
+		Embeds: map[string]DiscordEmbed{
+			"GotCharacter":   {Title: "Welcome {{ .Player.Name }}!", Color: 1},
+			"Day has passed": {Title: "Day {{ .Event.Value }}"},
+		},
+		Sender: testSenderConfig(),
+	})
+	if err == nil {
+		t.Fatal("NewDiscord(unknown event) did not return an error")
+	}
+
+	d, err = NewDiscord(DiscordConfig{
+		WebhookURLs: []string{standIn.server.URL, standIn.server.URL},
+		Embeds: map[string]DiscordEmbed{
+			"GotCharacter": {Title: "Welcome {{ .Player.Name }}!", Color: 1},
+			"DayHasPassed": {Title: "Day {{ .Event.Value }}"},
+		},
+		Sender: testSenderConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GotCharacter, SteamID: "1"}, testParams())
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "2"}, testParams())
+	d.Close()
+
+	got := standIn.requests()
+	if len(got) != 4 {
+		t.Fatalf("Discord sent %d requests, want 4 (2 events x 2 webhooks)", len(got))
+	}
+	body := string(got[0]) + string(got[1]) + string(got[2]) + string(got[3])
+	for _, want := range []string{"Welcome player1!", "Day 2"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Discord requests did not contain %q: %s", want, body)
+		}
+	}
+}
+
+func Test_Discord_ShowServer(t *testing.T) {
+	standIn := newWebhookStandIn(t)
+	d, err := NewDiscord(DiscordConfig{
+		WebhookURLs: []string{standIn.server.URL},
+		Embeds: map[string]DiscordEmbed{
+			"GameServerDisconnected": {Title: "{{ .Server }} is offline"},
+		},
+		ShowServer: true,
+		Sender:     testSenderConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Notify("hardcore", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerConnected}, testParams())
+	d.Notify("main", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerDisconnected}, testParams())
+	d.Close()
+
+	got := standIn.requests()
+	if len(got) != 2 {
+		t.Fatalf("Discord sent %d requests, want 2", len(got))
+	}
+	for i, want := range []struct{ title, footer string }{
+		{"Server is online", "hardcore"},
+		{"main is offline", "main"},
+	} {
+		var payload discordPayload
+		if err := json.Unmarshal(got[i], &payload); err != nil {
+			t.Fatalf("Discord sent invalid JSON: %v", err)
+		}
+		embed := payload.Embeds[0]
+		if embed.Title != want.title || embed.Footer == nil || embed.Footer.Text != want.footer {
+			t.Errorf("Discord requests[%d] = %s, want %q with the footer %q", i, got[i], want.title, want.footer)
+		}
+	}
+}
+
+func Test_Discord_CustomEvent(t *testing.T) {
+	portalUsed, err := vhlogwatcher.RegisterEventType("Portal used")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standIn := newWebhookStandIn(t)
+	d, err := NewDiscord(DiscordConfig{
+		WebhookURLs: []string{standIn.server.URL},
+		Embeds: map[string]DiscordEmbed{
+			"Portal used": {Title: "{{ .Event.Event }}: {{ .Player.Name }} to {{ .Event.Value }}"},
+		},
+		Sender: testSenderConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Notify("default", vhlogwatcher.VHLogEvent{Event: portalUsed, SteamID: "1", Value: "home"}, testParams())
+	d.Close()
+
+	got := standIn.requests()
+	if len(got) != 1 || !strings.Contains(string(got[0]), "Portal used: player1 to home") {
+		t.Errorf("Discord sent %q, want the embed of the custom event", got)
+	}
+}
+
+func Test_Discord_InvalidTemplate(t *testing.T) {
+	_, err := NewDiscord(DiscordConfig{
+		Embeds: map[string]DiscordEmbed{"GotCharacter": {Title: "{{ .Player.Name "}},
+	})
+	if err == nil {
+		t.Error("NewDiscord(invalid template) did not return an error")
+	}
+}
+
+func Test_Discord_Retry(t *testing.T) {
+	cases := []struct {
+		codes        []int
+		wantRequests int
+	}{
+		{[]int{http.StatusTooManyRequests}, 2},
+		{[]int{http.StatusInternalServerError, http.StatusBadGateway}, 3},
+		{[]int{500, 500, 500, 500, 500}, 4}, // MaxRetries: 3
+		{[]int{http.StatusBadRequest}, 1},   // not retried
+	}
+
+	for i, c := range cases {
+		standIn := newWebhookStandIn(t, c.codes...)
+		d, _ := NewDiscord(DiscordConfig{
+			WebhookURLs: []string{standIn.server.URL},
+			Sender:      testSenderConfig(),
+		})
+		d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerConnected}, testParams())
+		d.Close()
+
+		if got := len(standIn.requests()); got != c.wantRequests {
+			t.Errorf("case[%d]: Discord sent %d requests, want %d", i, got, c.wantRequests)
+		}
+	}
+}
+
+func Test_Discord_RateLimit(t *testing.T) {
+	standIn := newWebhookStandIn(t)
+	cfg := testSenderConfig()
+	cfg.MinInterval = time.Millisecond * 50
+	d, _ := NewDiscord(DiscordConfig{WebhookURLs: []string{standIn.server.URL}, Sender: cfg})
+
+	for i := 0; i < 3; i++ {
+		d.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GameServerConnected}, testParams())
+	}
+	d.Close()
+
+	standIn.mu.Lock()
+	defer standIn.mu.Unlock()
+	if len(standIn.received) != 3 {
+		t.Fatalf("Discord sent %d requests, want 3", len(standIn.received))
+	}
+	for i := 1; i < len(standIn.received); i++ {
+		if d := standIn.received[i].Sub(standIn.received[i-1]); d < cfg.MinInterval {
+			t.Errorf("interval between requests[%d] and [%d] is %v, want >= %v", i-1, i, d, cfg.MinInterval)
+		}
+	}
+}
+
+func Test_Sender_RedactURL(t *testing.T) {
+	standIn := newWebhookStandIn(t, http.StatusBadRequest)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, base := range []string{standIn.server.URL, closed.URL} {
+		s := newSender(testSenderConfig())
+		_, err := s.do(request{method: http.MethodPost, url: base + "/api/webhooks/1/secret-token"})
+		s.close()
+
+		if err == nil {
+			t.Fatalf("sender#do(%s) returned nil", base)
+		}
+		if strings.Contains(err.Error(), "secret-token") || !strings.Contains(err.Error(), base) {
+			t.Errorf("sender#do(%s) returned %q, want the host without the token", base, err)
+		}
+	}
+}
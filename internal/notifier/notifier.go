@@ -0,0 +1,72 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// Notifier sends notifications of the log events.
+// Notify must not block the caller.
+type Notifier interface {
+	// server is the name of the server of the event.
+	Notify(server string, event vhlogwatcher.VHLogEvent, params vhstatus.Params)
+
+	// Close sends the queued notifications and stops the notifier.
+	Close()
+}
+
+// Data is the data to render the templates of the notifications.
+type Data struct {
+	Server string                  `json:"server"` // name of the server, e.g. "default".
+	Event  vhlogwatcher.VHLogEvent `json:"event"`
+	Params vhstatus.Params         `json:"params"` // Params after the event has been applied.
+	Player vhstatus.Player         `json:"player"` // player of the event, zero-value if not found.
+	Raid   vhstatus.Raid           `json:"raid"`   // raid of the RandomEvent, zero-value for the other events.
+}
+
+func NewData(server string, event vhlogwatcher.VHLogEvent, params vhstatus.Params) Data {
+	data := Data{Server: server, Event: event, Params: params}
+	if event.Event == vhlogwatcher.RandomEvent {
+		for _, r := range params.RecentRaids {
+			if r.Name == event.Value && r.StartedAt.Equal(event.Timestamp) {
+				data.Raid = r
+				break
+			}
+		}
+	}
+	for _, p := range params.Players {
+		if event.SteamID != "" && p.SteamID == event.SteamID {
+			data.Player = p
+			break
+		}
+		if event.SteamID == "" && event.Name != "" && p.Name == event.Name {
+			data.Player = p
+		}
+	}
+	return data
+}
+
+// templateFuncs are the functions available in the templates.
+var templateFuncs = template.FuncMap{
+	// json encodes the value as JSON, e.g. {"text": {{ json .Player.Name }}}
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+func render(tmpl *template.Template, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
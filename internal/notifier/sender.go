@@ -0,0 +1,204 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	senderQueueSize = 100
+	maxRetryDelay   = time.Minute
+)
+
+// SenderConfig is the configuration of the delivery of the notifications.
+type SenderConfig struct {
+	MinInterval    time.Duration // minimum interval between requests to the same URL.
+	MaxRetries     int           // maximum number of retries of a failed request.
+	RetryBaseDelay time.Duration // delay before the first retry, it doubles on each retry.
+	Client         *http.Client
+}
+
+func (cfg SenderConfig) withDefaults() SenderConfig {
+	if cfg.RetryBaseDelay <= 0 {
+		cfg.RetryBaseDelay = time.Second
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: time.Second * 10}
+	}
+	return cfg
+}
+
+type request struct {
+	method string
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// sender sends the requests to a URL in order, with rate limiting and
+// retries, on its own goroutine.
+type sender struct {
+	cfg   SenderConfig
+	queue chan request
+	wg    sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newSender(cfg SenderConfig) *sender {
+	s := &sender{
+		cfg:   cfg.withDefaults(),
+		queue: make(chan request, senderQueueSize),
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// enqueue queues the request. If the queue is full, the request is dropped.
+func (s *sender) enqueue(req request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- req:
+	default:
+		log.Printf("notifier: queue is full, dropped a request to %s", redactURL(req.url))
+	}
+}
+
+// close sends the queued requests and stops the sender.
+func (s *sender) close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *sender) run() {
+	defer s.wg.Done()
+
+	var last time.Time
+	for req := range s.queue {
+		if wait := s.cfg.MinInterval - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+		if err := s.send(req); err != nil {
+			log.Printf("notifier: %v", err)
+		}
+		last = time.Now()
+	}
+}
+
+// send sends the request, and retries if it has failed temporarily.
+func (s *sender) send(req request) error {
+	delay := s.cfg.RetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.do(req)
+		if err == nil {
+			return nil
+		}
+		if retryAfter < 0 || attempt >= s.cfg.MaxRetries {
+			return err
+		}
+
+		if retryAfter == 0 {
+			retryAfter = delay
+			delay *= 2
+		}
+		if retryAfter > maxRetryDelay {
+			retryAfter = maxRetryDelay
+		}
+		time.Sleep(retryAfter)
+	}
+}
+
+// do sends the request once. If it has failed, it returns the error and the
+// delay before retrying: zero means the default backoff, and negative means
+// the request must not be retried.
+// The error has no URL but its scheme and host, as the URL may have the secret.
+func (s *sender) do(req request) (time.Duration, error) {
+	target := redactURL(req.url)
+	httpReq, err := http.NewRequest(req.method, req.url, bytes.NewReader(req.body))
+	if err != nil {
+		return -1, fmt.Errorf("%s %s: %v", req.method, target, stripURL(err))
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+
+	resp, err := s.cfg.Client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %v", req.method, target, stripURL(err))
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(resp.Header, body), fmt.Errorf("%s %s: rate limited", req.method, target)
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("%s %s: %s", req.method, target, resp.Status)
+	default:
+		return -1, fmt.Errorf("%s %s: %s: %s", req.method, target, resp.Status, body)
+	}
+}
+
+// redactURL returns the scheme and the host of the URL to be logged, without
+// the path and the query which may have the secret, e.g. the token of a
+// Discord webhook (/api/webhooks/<id>/<token>).
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// stripURL returns the cause of the error of net/http without the URL.
+func stripURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// parseRetryAfter returns the delay from the Retry-After header, or from
+// the "retry_after" field (in seconds) of the JSON body as Discord does.
+func parseRetryAfter(header http.Header, body []byte) time.Duration {
+	if sec, err := strconv.ParseFloat(header.Get("Retry-After"), 64); err == nil && sec > 0 {
+		return time.Duration(sec * float64(time.Second))
+	}
+
+	var v struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.Unmarshal(body, &v); err == nil && v.RetryAfter > 0 {
+		return time.Duration(v.RetryAfter * float64(time.Second))
+	}
+	return 0
+}
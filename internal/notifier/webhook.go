@@ -0,0 +1,152 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+const (
+	defaultWebhookBody            = "{{ json . }}"
+	defaultWebhookSignatureHeader = "X-Vhstatus-Signature"
+)
+
+// WebhookTarget is the configuration of an outgoing webhook.
+type WebhookTarget struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"` // POST if empty.
+	Headers map[string]string `json:"headers"`
+
+	// Events are the names of the events to send (see vhlogwatcher.ParseEventType).
+	// All events are sent if empty.
+	Events []string `json:"events"`
+
+	// Body is a text/template rendered with Data.
+	// Data is sent as JSON if empty.
+	Body string `json:"body"`
+
+	// Secret is the key to sign the body with HMAC-SHA256.
+	// The signature is sent as "sha256=<hex>" in SignatureHeader.
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signature_header"` // X-Vhstatus-Signature if empty.
+}
+
+type WebhookConfig struct {
+	Targets []WebhookTarget
+	Sender  SenderConfig
+}
+
+type webhookTarget struct {
+	WebhookTarget
+	events map[vhlogwatcher.EventType]bool
+	body   *template.Template
+	sender *sender
+}
+
+// Webhook sends the events to HTTP endpoints with templated payloads.
+type Webhook struct {
+	targets []*webhookTarget
+}
+
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	w := &Webhook{}
+	for i, t := range cfg.Targets {
+		target, err := newWebhookTarget(t)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("webhook: targets[%d]: %w", i, err)
+		}
+		target.sender = newSender(cfg.Sender)
+		w.targets = append(w.targets, target)
+	}
+	return w, nil
+}
+
+func newWebhookTarget(t WebhookTarget) (*webhookTarget, error) {
+	if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("url: invalid URL %q", t.URL)
+	}
+
+	if t.Method == "" {
+		t.Method = http.MethodPost
+	}
+	t.Method = strings.ToUpper(t.Method)
+	if t.Body == "" {
+		t.Body = defaultWebhookBody
+	}
+	if t.SignatureHeader == "" {
+		t.SignatureHeader = defaultWebhookSignatureHeader
+	}
+
+	target := &webhookTarget{
+		WebhookTarget: t,
+		events:        make(map[vhlogwatcher.EventType]bool),
+	}
+	for _, name := range t.Events {
+		et, ok := vhlogwatcher.ParseEventType(name)
+		if !ok {
+			return nil, fmt.Errorf("events: unknown event %q", name)
+		}
+		target.events[et] = true
+	}
+
+	body, err := parseTemplate("body", t.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	target.body = body
+
+	return target, nil
+}
+
+func (t *webhookTarget) accepts(et vhlogwatcher.EventType) bool {
+	return len(t.events) == 0 || t.events[et]
+}
+
+// Sign returns the signature of the body: "sha256=" + hex(HMAC-SHA256(secret, body)).
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) Notify(server string, event vhlogwatcher.VHLogEvent, params vhstatus.Params) {
+	data := NewData(server, event, params)
+	for _, t := range w.targets {
+		if !t.accepts(event.Event) {
+			continue
+		}
+
+		body, err := render(t.body, data)
+		if err != nil {
+			log.Printf("webhook: %s: %v", redactURL(t.URL), err)
+			continue
+		}
+
+		header := http.Header{}
+		header.Set("Content-Type", "application/json")
+		for k, v := range t.Headers {
+			header.Set(k, v)
+		}
+		if t.Secret != "" {
+			header.Set(t.SignatureHeader, Sign(t.Secret, []byte(body)))
+		}
+
+		t.sender.enqueue(request{method: t.Method, url: t.URL, header: header, body: []byte(body)})
+	}
+}
+
+func (w *Webhook) Close() {
+	for _, t := range w.targets {
+		t.sender.close()
+	}
+}
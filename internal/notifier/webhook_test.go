@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+)
+
+func Test_Sign(t *testing.T) {
+	// echo -n 'hello' | openssl dgst -sha256 -hmac 'secret'
+	want := "sha256=88aab3ede8d3adf94d26ab90d3bafd4a2083070c3bcce9c014ee04a443847c0b"
+	if got := Sign("secret", []byte("hello")); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func Test_Webhook_Notify(t *testing.T) {
+	standIn := newWebhookStandIn(t)
+	w, err := NewWebhook(WebhookConfig{
+		Targets: []WebhookTarget{
+			{
+				URL:     standIn.server.URL + "/slack",
+				Headers: map[string]string{"Authorization": "Bearer token"},
+				Events:  []string{"GotCharacter", "Player death"},
+				Body:    `{"text": {{ json (printf "%s: %s" .Event.Event .Player.Name) }}}`,
+				Secret:  "secret",
+			},
+			{
+				URL:    standIn.server.URL + "/home",
+				Method: "put",
+			},
+		},
+		Sender: testSenderConfig(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := testParams()
+	w.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.GotCharacter, SteamID: "1", Name: "player1"}, params)
+	w.Notify("default", vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "3"}, params)
+	w.Close()
+
+	standIn.mu.Lock()
+	defer standIn.mu.Unlock()
+
+	// 1 request to /slack, 2 requests to /home.
+	if len(standIn.bodies) != 3 {
+		t.Fatalf("Webhook sent %d requests, want 3", len(standIn.bodies))
+	}
+
+	var slack, home [][]byte
+	var slackHeader http.Header
+	for i, _ := range standIn.bodies {
+		if standIn.methods[i] == http.MethodPost {
+			slack = append(slack, standIn.bodies[i])
+			slackHeader = standIn.headers[i]
+		} else if standIn.methods[i] == http.MethodPut {
+			home = append(home, standIn.bodies[i])
+		}
+	}
+
+	if len(slack) != 1 || string(slack[0]) != `{"text": "Got Character: player1"}` {
+		t.Errorf("Webhook sent %q to the slack target", slack)
+	} else {
+		if got := slackHeader.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Webhook Authorization header = %q, want %q", got, "Bearer token")
+		}
+		if got, want := slackHeader.Get("X-Vhstatus-Signature"), Sign("secret", slack[0]); got != want {
+			t.Errorf("Webhook signature = %q, want %q", got, want)
+		}
+	}
+
+	if len(home) != 2 {
+		t.Fatalf("Webhook sent %d requests to the home target, want 2", len(home))
+	}
+	var data struct {
+		Server string                 `json:"server"`
+		Event  map[string]interface{} `json:"event"`
+		Params map[string]interface{} `json:"params"`
+	}
+	if err := json.Unmarshal(home[1], &data); err != nil {
+		t.Fatalf("Webhook default body is not JSON: %v", err)
+	}
+	if data.Server != "default" || data.Event["event"] != "DayHasPassed" || data.Params["world_name"] != "test-world" {
+		t.Errorf("Webhook default body = %s", home[1])
+	}
+}
+
+func Test_NewWebhook_Invalid(t *testing.T) {
+	cases := []WebhookTarget{
+		{URL: ""},
+		{URL: "ftp://example.com"},
+		{URL: "http://example.com", Events: []string{"unknown"}},
+		{URL: "http://example.com", Body: "{{ .Event "},
+	}
+
+	for i, c := range cases {
+		if _, err := NewWebhook(WebhookConfig{Targets: []WebhookTarget{c}}); err == nil {
+			t.Errorf("NewWebhook(case[%d]) did not return an error", i)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package vhlogwatcher
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultLogGlobs are the file name patterns of the past log files rotated by
+// LinuxGSM.
+var DefaultLogGlobs = []string{"vhserver-console-*.log"}
+
+// compressedExts are the extensions of the compressed log files.
+var compressedExts = []string{".gz", ".zst"}
+
+// IsCompressed reports whether the log file is compressed, by its extension.
+func IsCompressed(logpath string) bool {
+	for _, ext := range compressedExts {
+		if strings.HasSuffix(logpath, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// zstdReadCloser closes the decoder and the file.
+type zstdReadCloser struct {
+	*zstd.Decoder
+	file *os.File
+}
+
+func (r zstdReadCloser) Close() error {
+	r.Decoder.Close()
+	return r.file.Close()
+}
+
+// gzipReadCloser closes the gzip reader and the file.
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// openLog opens the log file, which is decompressed if its extension is .gz
+// or .zst. The offsets of a compressed file are of the decompressed lines.
+func openLog(logpath string) (io.ReadCloser, error) {
+	file, err := os.Open(logpath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filepath.Ext(logpath) {
+	case ".gz":
+		r, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", logpath, err)
+		}
+		return gzipReadCloser{r, file}, nil
+
+	case ".zst":
+		r, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", logpath, err)
+		}
+		return zstdReadCloser{r, file}, nil
+	}
+	return file, nil
+}
+
+// openLogAt opens the log file at the byte offset of the decompressed lines.
+func openLogAt(logpath string, offset int64) (io.ReadCloser, error) {
+	r, err := openLog(logpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := r.(*os.File); ok {
+		_, err = file.Seek(offset, io.SeekStart)
+	} else {
+		// a compressed file can not seek, skip the lines before the offset.
+		// if the offset is beyond the end, nothing is read like the plain file.
+		if _, err = io.CopyN(io.Discard, r, offset); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("%s: %w", logpath, err)
+	}
+	return r, nil
+}
+
+// PastLogFiles returns the log files in the directory which match any of
+// globs, or its compressed file (.gz or .zst), in chronological order.
+//
+// The files are ordered by the time of their first console log line, so that
+// e.g. "vhserver-console.log.1" and "vhserver-console.log.2.gz" of logrotate
+// are ordered correctly. The files without it are ordered by the modification
+// time. A compressed file whose plain file also exists (e.g. it is being
+// compressed) is skipped.
+func PastLogFiles(dir string, globs []string) ([]string, error) {
+	found := make(map[string]bool)
+	for _, glob := range globs {
+		for _, ext := range append([]string{""}, compressedExts...) {
+			matches, err := filepath.Glob(filepath.Join(dir, glob+ext))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", glob, err)
+			}
+			for _, m := range matches {
+				found[m] = true
+			}
+		}
+	}
+
+	type logFile struct {
+		path string
+		time time.Time
+	}
+	files := make([]logFile, 0, len(found))
+	for path := range found {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if IsCompressed(path) && found[strings.TrimSuffix(path, filepath.Ext(path))] {
+			continue
+		}
+
+		t := info.ModTime()
+		if head, err := ReadHead(path); err == nil && head != "" {
+			t = parseLogTime(consoleLogTimeLayout, reConsoleLog.FindString(head))
+		}
+		files = append(files, logFile{path, t})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].time.Equal(files[j].time) {
+			return files[i].time.Before(files[j].time)
+		}
+		return files[i].path < files[j].path
+	})
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
@@ -0,0 +1,150 @@
+package vhlogwatcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// writeLog writes the log file, which is compressed by its extension.
+func writeLog(t *testing.T, path, content string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch filepath.Ext(path) {
+	case ".gz":
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+	case ".zst":
+		w, _ := zstd.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+	default:
+		buf.WriteString(content)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ReadFrom_Compressed(t *testing.T) {
+	lines := []string{
+		"04/10/2021 12:00:00: Valheim version:0.148.6",
+		"04/10/2021 12:00:01: Game server connected",
+		"04/10/2021 12:34:59: Game server disconnected",
+	}
+	content := lines[0] + "\n" + lines[1] + "\n" + lines[2] + "\n"
+
+	for _, ext := range []string{".log", ".log.gz", ".log.zst"} {
+		path := filepath.Join(t.TempDir(), "vhserver-console-1"+ext)
+		writeLog(t, path, content)
+
+		if head, err := ReadHead(path); err != nil || head != lines[0] {
+			t.Errorf("ReadHead(%s) = %q, %v, want %q", ext, head, err, lines[0])
+		}
+
+		// the offset is of the decompressed lines.
+		var events []VHLogEvent
+		err := NewWatcher().ReadFrom(context.Background(), path, linesOffset(lines, 1), func(e VHLogEvent) {
+			events = append(events, e)
+		})
+		if err != nil {
+			t.Fatalf("ReadFrom(%s) returned %v", ext, err)
+		}
+		if len(events) != 2 || events[0].Event != GameServerConnected || events[1].Event != GameServerDisconnected {
+			t.Fatalf("ReadFrom(%s) = %+v, want the last 2 lines", ext, events)
+		}
+		if pos := events[1].Position; pos.Head != lines[0] || pos.Offset != linesOffset(lines, 3) {
+			t.Errorf("ReadFrom(%s) position = %+v, want %d of %q", ext, pos, linesOffset(lines, 3), lines[0])
+		}
+
+		// nothing is read beyond the end.
+		events = nil
+		if err := NewWatcher().ReadFrom(context.Background(), path, 1000, func(e VHLogEvent) {
+			events = append(events, e)
+		}); err != nil || len(events) != 0 {
+			t.Errorf("ReadFrom(%s, beyond the end) = %d events, %v", ext, len(events), err)
+		}
+	}
+}
+
+func Test_ReadFrom_CorruptedArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console-1.log.gz")
+	ioutil.WriteFile(path, []byte("not gzip"), 0644)
+
+	if err := NewWatcher().ReadFrom(context.Background(), path, 0, func(VHLogEvent) {}); err == nil {
+		t.Error("ReadFrom(corrupted .gz) did not return an error")
+	}
+}
+
+func Test_PastLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mtime time.Time) {
+		path := filepath.Join(dir, name)
+		writeLog(t, path, content)
+		os.Chtimes(path, mtime, mtime)
+	}
+
+	now := time.Now()
+	// logrotate numbers the files from the newest, and the names are not in
+	// chronological order.
+	write("vhserver-console.log.3.zst", "04/01/2021 00:00:00: first\n", now)
+	write("vhserver-console.log.2.gz", "04/02/2021 00:00:00: second\n", now)
+	write("vhserver-console.log.1", "04/03/2021 00:00:00: third\n", now)
+	// LinuxGSM
+	write("vhserver-console-2021-04-04.log.gz", "04/04/2021 00:00:00: fourth\n", now)
+	// without a timestamp, ordered by the modification time.
+	write("vhserver-console-bepinex.log", "[Info   :   BepInEx] Loading\n", time.Date(2021, 4, 2, 12, 0, 0, 0, time.Local))
+	// being compressed, the plain file is read.
+	write("vhserver-console-2021-04-05.log", "04/05/2021 00:00:00: fifth\n", now)
+	write("vhserver-console-2021-04-05.log.gz", "04/05/2021 00:00:00: fif", now)
+	// not matched.
+	write("vhserver-console.log", "04/06/2021 00:00:00: current\n", now)
+	write("vhserver-console-2021-04-04.log.bz2", "04/04/2021 00:00:00: fourth\n", now)
+
+	got, err := PastLogFiles(dir, []string{"vhserver-console-*.log", "vhserver-console.log.[0-9]"})
+	if err != nil {
+		t.Fatalf("PastLogFiles returned %v", err)
+	}
+	want := []string{
+		"vhserver-console.log.3.zst",
+		"vhserver-console.log.2.gz",
+		"vhserver-console-bepinex.log",
+		"vhserver-console.log.1",
+		"vhserver-console-2021-04-04.log.gz",
+		"vhserver-console-2021-04-05.log",
+	}
+	for i := range want {
+		want[i] = filepath.Join(dir, want[i])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PastLogFiles = %q, want %q", got, want)
+	}
+
+	if _, err := PastLogFiles(dir, []string{"[a-"}); err == nil {
+		t.Error("PastLogFiles(bad pattern) did not return an error")
+	}
+}
+
+func Test_ResumeOffsets_Compressed(t *testing.T) {
+	dir := t.TempDir()
+	lines := []string{"04/02/2021 00:00:00: second", "04/02/2021 00:00:01: x"}
+	files := []string{filepath.Join(dir, "vhserver-console-1.log.gz"), filepath.Join(dir, "vhserver-console.log")}
+	writeLog(t, files[0], lines[0]+"\n"+lines[1]+"\n")
+	writeLog(t, files[1], "04/03/2021 00:00:00: third\n")
+
+	// the compressed file may be smaller than the offset.
+	offset := linesOffset(lines, 2)
+	if got := ResumeOffsets(files, lines[0], offset, time.Time{}); !reflect.DeepEqual(got, []int64{offset, 0}) {
+		t.Errorf("ResumeOffsets = %v, want %v", got, []int64{offset, 0})
+	}
+}
@@ -0,0 +1,251 @@
+package vhlogwatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Confidence is how confident the SteamID of a character event is.
+//
+// The "Got character" log only contains the character name, so its SteamID is
+// guessed from the handshakes which have not been matched with a character yet.
+type Confidence int
+
+const (
+	ConfidenceNone   Confidence = iota // no SteamID is found.
+	ConfidenceLow                      // guessed from several candidates.
+	ConfidenceMedium                   // chosen from several candidates by the name history.
+	ConfidenceHigh                     // only one candidate, or the character is already in the game.
+)
+
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceNone:
+		return "none"
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return ""
+	}
+}
+
+// MarshalText encodes the confidence as its name.
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// NameHistory knows the character names used by the SteamIDs.
+type NameHistory interface {
+	// SteamIDsByName returns the SteamIDs of the players who have used the name.
+	SteamIDsByName(name string) []string
+
+	// NamesBySteamID returns the names which the player has used.
+	NamesBySteamID(steamID string) []string
+}
+
+// DefaultHandshakeTimeout is the time to wait for the character of a handshake.
+const DefaultHandshakeTimeout = time.Minute * 5
+
+// Correlator correlates the characters with the handshakes of the connections.
+//
+// A handshake waits in a queue until its character is logged, the connection
+// is closed, or it times out. When a character is logged, the SteamID is chosen
+// as follows:
+//
+//  1. the SteamID which is already in the game with the name (e.g. respawn).
+//  2. the only pending handshake.
+//  3. the pending handshake whose SteamID has used the name before.
+//  4. the oldest pending handshake whose SteamID has not used other names.
+//  5. the oldest pending handshake.
+//
+// If no handshake is pending, the SteamID is looked up from the name history.
+type Correlator struct {
+	Timeout time.Duration // zero means DefaultHandshakeTimeout.
+	History NameHistory   // optional.
+
+	mu      sync.Mutex
+	pending []pendingHandshake
+	ingame  map[string]string // SteamID -> character name.
+	remote  map[string]string // remote ID of a crossplay socket -> SteamID.
+}
+
+type pendingHandshake struct {
+	steamID string
+	at      time.Time
+}
+
+// DefaultCorrelator is the correlator used by ReadVHLog and WatchVHLog.
+// It is shared, so that a connection can be correlated across the log files.
+var DefaultCorrelator = &Correlator{}
+
+// Reset forgets all connections.
+func (c *Correlator) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = nil
+	c.ingame = nil
+	c.remote = nil
+}
+
+// Correlate updates the state of the connections by the event.
+// For a character event or a kick by the name, it returns the event with the
+// SteamID and the confidence.
+func (c *Correlator) Correlate(event VHLogEvent) VHLogEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ingame == nil {
+		c.ingame = make(map[string]string)
+	}
+	if c.remote == nil {
+		c.remote = make(map[string]string)
+	}
+
+	// the later lines of a crossplay connection have the remote ID.
+	if id, ok := c.remote[event.SteamID]; ok {
+		event.SteamID, event.Platform = id, PlatformOf(id)
+	}
+
+	switch event.Event {
+	case ValheimVersion, GameServerDisconnected:
+		// the server has (re)started, all connections are gone.
+		c.pending = nil
+		c.ingame = make(map[string]string)
+		c.remote = make(map[string]string)
+
+	case GotHandshake:
+		if event.Value != "" {
+			c.remote[event.Value] = event.SteamID
+		}
+		c.removePending(event.SteamID)
+		delete(c.ingame, event.SteamID)
+		c.pending = append(c.pending, pendingHandshake{steamID: event.SteamID, at: event.Timestamp})
+
+	case Disconnection:
+		c.removePending(event.SteamID)
+		delete(c.ingame, event.SteamID)
+		for remoteID, id := range c.remote {
+			if id == event.SteamID {
+				delete(c.remote, remoteID)
+			}
+		}
+
+	case WrongPassword, IncompatibleVersion, Banned:
+		// the peer will never get a character.
+		c.removePending(event.SteamID)
+
+	case Kicked:
+		if event.SteamID == "" {
+			event.SteamID, event.Confidence = c.resolveIngame(event.Name)
+			event.Platform = PlatformOf(event.SteamID)
+		}
+
+	case GotCharacter:
+		if event.SteamID == "" {
+			event.SteamID, event.Confidence = c.resolve(event.Name, event.Timestamp)
+			event.Platform = PlatformOf(event.SteamID)
+		}
+		if event.SteamID != "" {
+			c.removePending(event.SteamID)
+			c.ingame[event.SteamID] = event.Name
+		}
+	}
+	return event
+}
+
+// resolveIngame returns the SteamID of the character in the game.
+func (c *Correlator) resolveIngame(name string) (string, Confidence) {
+	for steamID, n := range c.ingame {
+		if n == name {
+			return steamID, ConfidenceHigh
+		}
+	}
+	return "", ConfidenceNone
+}
+
+func (c *Correlator) resolve(name string, at time.Time) (string, Confidence) {
+	if steamID, confidence := c.resolveIngame(name); steamID != "" {
+		return steamID, confidence
+	}
+
+	c.expire(at)
+
+	var known []string
+	if c.History != nil {
+		known = c.History.SteamIDsByName(name)
+	}
+
+	switch len(c.pending) {
+	case 0:
+		if len(known) == 1 {
+			return known[0], ConfidenceLow
+		}
+		return "", ConfidenceNone
+	case 1:
+		return c.pending[0].steamID, ConfidenceHigh
+	}
+
+	for _, p := range c.pending {
+		if contains(known, p.steamID) {
+			return p.steamID, ConfidenceMedium
+		}
+	}
+	if c.History != nil {
+		for _, p := range c.pending {
+			if !c.hasOtherName(p.steamID, name) {
+				return p.steamID, ConfidenceLow
+			}
+		}
+	}
+	return c.pending[0].steamID, ConfidenceLow
+}
+
+// hasOtherName returns true if the SteamID has used a name other than the name.
+// The caller must ensure c.History is not nil.
+func (c *Correlator) hasOtherName(steamID, name string) bool {
+	for _, n := range c.History.NamesBySteamID(steamID) {
+		if n != name {
+			return true
+		}
+	}
+	return false
+}
+
+// expire removes the handshakes which have waited for the character too long.
+func (c *Correlator) expire(now time.Time) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+
+	pending := c.pending[:0]
+	for _, p := range c.pending {
+		if now.Sub(p.at) <= timeout {
+			pending = append(pending, p)
+		}
+	}
+	c.pending = pending
+}
+
+func (c *Correlator) removePending(steamID string) {
+	for i, p := range c.pending {
+		if p.steamID == steamID {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package vhlogwatcher
+
+import (
+	"testing"
+	"time"
+)
+
+type testNameHistory map[string]string // SteamID -> name
+
+func (h testNameHistory) SteamIDsByName(name string) []string {
+	var ids []string
+	for id, n := range h {
+		if n == name {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (h testNameHistory) NamesBySteamID(steamID string) []string {
+	if n, ok := h[steamID]; ok {
+		return []string{n}
+	}
+	return nil
+}
+
+func Test_Correlator(t *testing.T) {
+	base := time.Date(2021, 4, 10, 12, 0, 0, 0, time.Local)
+	at := func(sec int) time.Time { return base.Add(time.Second * time.Duration(sec)) }
+	handshake := func(sec int, id string) VHLogEvent {
+		return VHLogEvent{Event: GotHandshake, Timestamp: at(sec), SteamID: id}
+	}
+	character := func(sec int, name string) VHLogEvent {
+		return VHLogEvent{Event: GotCharacter, Timestamp: at(sec), Name: name}
+	}
+	disconnection := func(sec int, id string) VHLogEvent {
+		return VHLogEvent{Event: Disconnection, Timestamp: at(sec), SteamID: id}
+	}
+
+	type want struct {
+		steamID    string
+		confidence Confidence
+	}
+	cases := []struct {
+		name    string
+		history testNameHistory
+		events  []VHLogEvent
+		want    []want // of the GotCharacter and Kicked events.
+	}{
+		{
+			"a player",
+			nil,
+			[]VHLogEvent{handshake(0, "1"), character(10, "player1")},
+			[]want{{"1", ConfidenceHigh}},
+		},
+		{
+			"two players join at the same moment",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(0, "2"),
+				character(10, "player1"), character(10, "player2"),
+			},
+			[]want{{"1", ConfidenceLow}, {"2", ConfidenceHigh}},
+		},
+		{
+			"two known players join in reverse order",
+			testNameHistory{"1": "player1", "2": "player2"},
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(1, "2"),
+				character(10, "player2"), character(11, "player1"),
+			},
+			[]want{{"2", ConfidenceMedium}, {"1", ConfidenceHigh}},
+		},
+		{
+			"a new player and a known player",
+			testNameHistory{"1": "player1"},
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(1, "2"),
+				character(10, "newbie"), character(11, "player1"),
+			},
+			[]want{{"2", ConfidenceLow}, {"1", ConfidenceHigh}},
+		},
+		{
+			"respawn",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), character(10, "player1"),
+				handshake(20, "2"), character(30, "player1"),
+			},
+			[]want{{"1", ConfidenceHigh}, {"1", ConfidenceHigh}},
+		},
+		{
+			"disconnected before the character",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(1, "2"), disconnection(5, "1"),
+				character(10, "player2"),
+			},
+			[]want{{"2", ConfidenceHigh}},
+		},
+		{
+			"wrong password before the character",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(1, "2"),
+				{Event: WrongPassword, Timestamp: at(2), SteamID: "1"},
+				character(10, "player2"),
+			},
+			[]want{{"2", ConfidenceHigh}},
+		},
+		{
+			"kicked by the name",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), character(10, "player1"),
+				{Event: Kicked, Timestamp: at(20), Name: "player1"},
+				{Event: Kicked, Timestamp: at(20), Name: "unknown"},
+			},
+			[]want{{"1", ConfidenceHigh}, {"1", ConfidenceHigh}, {"", ConfidenceNone}},
+		},
+		{
+			"crossplay",
+			nil,
+			[]VHLogEvent{
+				{Event: GotHandshake, Timestamp: at(0), SteamID: "Xbox_1", Platform: PlatformXbox, Value: "PlayFab_A"},
+				{Event: GotHandshake, Timestamp: at(1), SteamID: "Xbox_2", Platform: PlatformXbox, Value: "PlayFab_B"},
+				{Event: Disconnection, Timestamp: at(5), SteamID: "PlayFab_A", Platform: PlatformPlayFab},
+				character(10, "player2"),
+			},
+			[]want{{"Xbox_2", ConfidenceHigh}},
+		},
+		{
+			"timed out handshake",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), handshake(400, "2"),
+				character(410, "player2"),
+			},
+			[]want{{"2", ConfidenceHigh}},
+		},
+		{
+			"no handshake",
+			testNameHistory{"1": "player1"},
+			[]VHLogEvent{character(10, "player1"), character(10, "unknown")},
+			[]want{{"1", ConfidenceLow}, {"", ConfidenceNone}},
+		},
+		{
+			"server restarted",
+			nil,
+			[]VHLogEvent{
+				handshake(0, "1"), character(10, "player1"),
+				{Event: ValheimVersion, Timestamp: at(20)},
+				character(30, "player1"),
+			},
+			[]want{{"1", ConfidenceHigh}, {"", ConfidenceNone}},
+		},
+	}
+
+	for _, c := range cases {
+		corr := &Correlator{}
+		if c.history != nil {
+			corr.History = c.history
+		}
+
+		var got []want
+		for _, e := range c.events {
+			if e = corr.Correlate(e); e.Event == GotCharacter || e.Event == Kicked {
+				got = append(got, want{e.SteamID, e.Confidence})
+			}
+		}
+
+		if len(got) != len(c.want) {
+			t.Errorf("Correlator(%s) returned %v, want %v", c.name, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("Correlator(%s) character[%d] = %+v, want %+v", c.name, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func Test_Correlator_RemoteID(t *testing.T) {
+	corr := &Correlator{}
+	corr.Correlate(VHLogEvent{Event: GotHandshake, SteamID: "Xbox_1", Platform: PlatformXbox, Value: "PlayFab_A"})
+
+	got := corr.Correlate(VHLogEvent{Event: Disconnection, SteamID: "PlayFab_A", Platform: PlatformPlayFab})
+	if got.SteamID != "Xbox_1" || got.Platform != PlatformXbox {
+		t.Errorf("Correlate(Closing socket of the remote ID) = %+v, want Xbox_1", got)
+	}
+
+	// the remote ID is forgotten after the disconnection.
+	got = corr.Correlate(VHLogEvent{Event: Disconnection, SteamID: "PlayFab_A", Platform: PlatformPlayFab})
+	if got.SteamID != "PlayFab_A" {
+		t.Errorf("Correlate(after the disconnection) = %+v, want PlayFab_A", got)
+	}
+}
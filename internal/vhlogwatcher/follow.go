@@ -0,0 +1,192 @@
+package vhlogwatcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultPollInterval is the interval to check the log file for the new lines,
+// the rotation and the truncation.
+const DefaultPollInterval = time.Millisecond * 250
+
+// follower reads the lines appended to the log file at a path.
+//
+// LinuxGSM rotates the log file on every start of the server: the file is
+// renamed to "vhserver-console-<date>.log" and a new file is created at the
+// path. The follower reads the renamed file to the end before it opens the new
+// one, because the server may still be writing to it. A file truncated in place
+// (e.g. copytruncate) is read again from the beginning.
+type follower struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo // of the opened file.
+	reader  *bufio.Reader
+	offset  int64  // bytes read from the opened file, including partial.
+	partial string // the last line which has not been terminated yet.
+}
+
+// openFollower opens the log file at the byte offset.
+// If the offset is negative, it opens the file at the end. If the offset is
+// beyond the end, the file is read from the beginning.
+func openFollower(path string, offset int64) (*follower, error) {
+	f := &follower{path: path}
+	if err := f.open(offset); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *follower) open(offset int64) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	switch {
+	case offset < 0:
+		offset = info.Size()
+	case offset > info.Size():
+		// the file has been truncated since the offset was taken.
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file, f.info, f.offset, f.partial = file, info, offset, ""
+	f.reader = bufio.NewReader(file)
+	return nil
+}
+
+func (f *follower) close() {
+	f.file.Close()
+}
+
+// readLines calls fn for each line written to the file, until the end of the
+// file. A line without the newline is kept until it is terminated.
+func (f *follower) readLines(ctx context.Context, fn func(line string, size int64)) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk, err := f.reader.ReadString('\n')
+		f.offset += int64(len(chunk))
+		f.partial += chunk
+		if err == nil {
+			line := f.partial
+			f.partial = ""
+			fn(line, int64(len(line)))
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+}
+
+// flushPartial calls fn for the line without the newline, e.g. the last line
+// of a rotated file.
+func (f *follower) flushPartial(fn func(line string, size int64)) {
+	if f.partial != "" {
+		line := f.partial
+		f.partial = ""
+		fn(line, int64(len(line)))
+	}
+}
+
+// followState is the state of the file at the path.
+type followState int
+
+const (
+	fileUnchanged followState = iota
+	fileRotated               // another file has been created at the path.
+	fileTruncated             // the opened file has been shrunk.
+)
+
+// check returns the state of the file at the path.
+// While the path does not exist (e.g. just after the rename), the opened file
+// is read as it is.
+func (f *follower) check() followState {
+	if info, err := os.Stat(f.path); err == nil && !os.SameFile(f.info, info) {
+		return fileRotated
+	}
+	if info, err := f.file.Stat(); err == nil && info.Size() < f.offset {
+		return fileTruncated
+	}
+	return fileUnchanged
+}
+
+// WatchFrom is WatchVHLogFrom with the correlator of the Watcher.
+//
+// It follows the path through the log rotation and the truncation, so the
+// events of a restart of the server are neither lost nor repeated.
+func (w *Watcher) WatchFrom(ctx context.Context, logpath string, offset int64, callback func(VHLogEvent)) error {
+	f, err := openFollower(logpath, offset)
+	if err != nil {
+		return err
+	}
+	defer f.close()
+
+	interval := w.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lr := newLogReader(logpath, f.offset, w, callback)
+	read := func() error {
+		err := f.readLines(ctx, lr.processLine)
+		if err != nil && err != ctx.Err() {
+			return fmt.Errorf("%s: %w", logpath, err)
+		}
+		return err
+	}
+
+	for {
+		if err := read(); err != nil {
+			return err
+		}
+
+		switch f.check() {
+		case fileRotated:
+			// the writer may have written to the old file since the last read.
+			if err := read(); err != nil {
+				return err
+			}
+			f.flushPartial(lr.processLine)
+			if err := f.open(0); err != nil {
+				return fmt.Errorf("%s: %w", logpath, err)
+			}
+			lr = newLogReader(logpath, 0, w, callback)
+			continue
+
+		case fileTruncated:
+			if err := f.open(0); err != nil {
+				return fmt.Errorf("%s: %w", logpath, err)
+			}
+			lr = newLogReader(logpath, 0, w, callback)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
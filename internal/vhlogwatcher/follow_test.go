@@ -0,0 +1,184 @@
+package vhlogwatcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watchForTest watches the log file until the test ends, and returns the
+// channel of the events.
+func watchForTest(t *testing.T, path string, offset int64) <-chan VHLogEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan VHLogEvent, 100)
+	done := make(chan error)
+	w := NewWatcher()
+	w.PollInterval = time.Millisecond * 10
+	go func() {
+		done <- w.WatchFrom(ctx, path, offset, func(e VHLogEvent) { events <- e })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("WatchFrom returned %v, want context.Canceled", err)
+		}
+	})
+	return events
+}
+
+// waitEvents returns the events, or fails if they do not come in time.
+func waitEvents(t *testing.T, events <-chan VHLogEvent, n int) []VHLogEvent {
+	t.Helper()
+
+	var ret []VHLogEvent
+	for len(ret) < n {
+		select {
+		case e := <-events:
+			ret = append(ret, e)
+		case <-time.After(time.Second * 5):
+			t.Fatalf("got %d events %+v, want %d", len(ret), ret, n)
+		}
+	}
+	return ret
+}
+
+// expectNoEvent fails if an event comes within a few poll intervals.
+func expectNoEvent(t *testing.T, events <-chan VHLogEvent) {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		t.Errorf("got an unexpected event %+v", e)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func appendLog(t *testing.T, path, text string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_WatchFrom_LinuxGSMRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vhserver-console.log")
+	rotated := filepath.Join(dir, "vhserver-console-2021-04-10-123500.log")
+
+	oldLines := []string{
+		"04/10/2021 12:00:00: Valheim version:0.148.6\n",
+		"04/10/2021 12:00:01: Game server connected\n",
+		"04/10/2021 12:34:58: Closing socket 76561198000000001\n",
+		"04/10/2021 12:34:59: Game server disconnected", // without the newline.
+	}
+	newLines := []string{
+		"04/10/2021 12:35:00: Valheim version:0.148.7\n",
+		"04/10/2021 12:35:01: Game server connected\n",
+	}
+	offsetOf := func(lines []string, n int) (offset int64) {
+		for _, line := range lines[:n] {
+			offset += int64(len(line))
+		}
+		return offset
+	}
+
+	ioutil.WriteFile(path, []byte(oldLines[0]+oldLines[1]), 0644)
+	events := watchForTest(t, path, 0)
+	waitEvents(t, events, 2)
+
+	// the server keeps writing to the file which it has opened.
+	server, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	defer server.Close()
+
+	// LinuxGSM restarts the server: the server stops, the log file is renamed,
+	// and a new log file is created for the new server.
+	server.WriteString(oldLines[2])
+	os.Rename(path, rotated)
+	server.WriteString(oldLines[3])
+	ioutil.WriteFile(path, []byte(newLines[0]), 0644)
+	appendLog(t, path, newLines[1])
+
+	got := waitEvents(t, events, 4)
+	want := []struct {
+		event  EventType
+		head   string
+		offset int64
+	}{
+		{Disconnection, oldLines[0], offsetOf(oldLines, 3)},
+		{GameServerDisconnected, oldLines[0], offsetOf(oldLines, 4)},
+		{ValheimVersion, newLines[0], offsetOf(newLines, 1)},
+		{GameServerConnected, newLines[0], offsetOf(newLines, 2)},
+	}
+	for i, w := range want {
+		w.head = strings.TrimSpace(w.head)
+		if got[i].Event != w.event || got[i].Position.Head != w.head || got[i].Position.Offset != w.offset {
+			t.Errorf("events[%d] = %v %+v, want %v at %d of %q", i, got[i].Event, got[i].Position, w.event, w.offset, w.head)
+		}
+	}
+	expectNoEvent(t, events)
+
+	// the next rotation is followed as well.
+	os.Rename(path, filepath.Join(dir, "vhserver-console-2021-04-10-140000.log"))
+	appendLog(t, path, "04/10/2021 14:00:00: Valheim version:0.148.8\n")
+	if got := waitEvents(t, events, 1); got[0].Event != ValheimVersion || got[0].Value != "0.148.8" {
+		t.Errorf("event after the second rotation = %+v", got[0])
+	}
+	expectNoEvent(t, events)
+}
+
+func Test_WatchFrom_Truncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte("04/10/2021 12:00:00: Valheim version:0.148.6\n04/10/2021 12:00:01: Game server connected\n"), 0644)
+	events := watchForTest(t, path, 0)
+	waitEvents(t, events, 2)
+
+	// copytruncate: the content has been copied to another file.
+	os.Truncate(path, 0)
+	expectNoEvent(t, events)
+
+	newHead := "04/10/2021 12:35:00: Valheim version:0.148.7"
+	appendLog(t, path, newHead+"\n")
+	got := waitEvents(t, events, 1)
+	if got[0].Event != ValheimVersion || got[0].Position.Head != newHead || got[0].Position.Offset != int64(len(newHead)+1) {
+		t.Errorf("event after the truncation = %v %+v", got[0].Event, got[0].Position)
+	}
+	expectNoEvent(t, events)
+}
+
+func Test_WatchFrom_PartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, nil, 0644)
+	events := watchForTest(t, path, 0)
+
+	appendLog(t, path, "04/10/2021 12:00:01: Game server conn")
+	expectNoEvent(t, events)
+
+	appendLog(t, path, "ected\n")
+	got := waitEvents(t, events, 1)
+	if got[0].Event != GameServerConnected || got[0].Position.Offset != 43 {
+		t.Errorf("event of the partial line = %v %+v, want GameServerConnected at 43", got[0].Event, got[0].Position)
+	}
+}
+
+func Test_WatchFrom_OffsetBeyondEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte("04/10/2021 12:00:01: Game server connected\n"), 0644)
+
+	// the file has been replaced since the offset was taken.
+	events := watchForTest(t, path, 1000)
+	if got := waitEvents(t, events, 1); got[0].Event != GameServerConnected {
+		t.Errorf("event = %+v, want GameServerConnected", got[0])
+	}
+}
@@ -0,0 +1,63 @@
+package vhlogwatcher
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Platforms of the players.
+const (
+	PlatformSteam   = "Steam"
+	PlatformXbox    = "Xbox"
+	PlatformPlayFab = "PlayFab"
+)
+
+// playerIDPattern matches a player ID in the log:
+//
+//	76561198000000001        ... SteamID, logged without the crossplay.
+//	Steam_76561198000000001  ... platform ID, logged with the crossplay.
+//	Xbox_2535400000000000    ... platform ID of Xbox and Game Pass.
+//	playfab/0123456789ABCDEF ... remote ID of a PlayFab socket.
+const playerIDPattern = `(\d+|(?:Steam|Xbox|PlayFab|None)_[0-9A-Za-z]+|playfab\/[0-9A-Za-z]+)`
+
+var rePlayerID = regexp.MustCompile(`^` + playerIDPattern + `$`)
+
+// ParsePlayerID returns the ID of the player and the platform.
+// A Steam player has the plain SteamID on either way of the logging, so that
+// the player is the same with or without the crossplay. The players of the
+// other platforms have "<Platform>_<ID>".
+//
+// If s is not a player ID, ParsePlayerID returns false.
+func ParsePlayerID(s string) (id, platform string, ok bool) {
+	if !rePlayerID.MatchString(s) {
+		return "", "", false
+	}
+
+	switch {
+	case strings.HasPrefix(s, "playfab/"):
+		return PlatformPlayFab + "_" + strings.TrimPrefix(s, "playfab/"), PlatformPlayFab, true
+	case strings.HasPrefix(s, PlatformSteam+"_"):
+		return strings.TrimPrefix(s, PlatformSteam+"_"), PlatformSteam, true
+	case strings.Contains(s, "_"):
+		return s, s[:strings.Index(s, "_")], true
+	default:
+		return s, PlatformSteam, true
+	}
+}
+
+// PlatformOf returns the platform of the player ID returned by ParsePlayerID.
+func PlatformOf(id string) string {
+	if i := strings.Index(id, "_"); i >= 0 {
+		return id[:i]
+	}
+	if id == "" {
+		return ""
+	}
+	return PlatformSteam
+}
+
+// withPlayerID returns the event with the player ID and the platform.
+func withPlayerID(event VHLogEvent, s string) VHLogEvent {
+	event.SteamID, event.Platform, _ = ParsePlayerID(s)
+	return event
+}
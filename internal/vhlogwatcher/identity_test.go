@@ -0,0 +1,32 @@
+package vhlogwatcher
+
+import (
+	"testing"
+)
+
+func Test_ParsePlayerID(t *testing.T) {
+	cases := []struct {
+		s            string
+		wantID       string
+		wantPlatform string
+		wantOk       bool
+	}{
+		{"76561198000000001", "76561198000000001", PlatformSteam, true},
+		{"Steam_76561198000000001", "76561198000000001", PlatformSteam, true},
+		{"Xbox_2535400000000001", "Xbox_2535400000000001", PlatformXbox, true},
+		{"playfab/0123456789ABCDEF", "PlayFab_0123456789ABCDEF", PlatformPlayFab, true},
+		{"PlayFab_0123456789ABCDEF", "PlayFab_0123456789ABCDEF", PlatformPlayFab, true},
+		{"Big_Bob", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, c := range cases {
+		id, platform, ok := ParsePlayerID(c.s)
+		if id != c.wantID || platform != c.wantPlatform || ok != c.wantOk {
+			t.Errorf("ParsePlayerID(%q) = (%q, %q, %t), want (%q, %q, %t)", c.s, id, platform, ok, c.wantID, c.wantPlatform, c.wantOk)
+		}
+		if ok && PlatformOf(id) != platform {
+			t.Errorf("PlatformOf(%q) = %q, want %q", id, PlatformOf(id), platform)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package vhlogwatcher
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_PipeSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan VHLogEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- (&PipeSource{Path: path}).Watch(ctx, NewWatcher(), func(e VHLogEvent) { events <- e })
+	}()
+
+	// the writer comes and goes, e.g. the container is restarted.
+	for _, line := range []string{
+		"04/10/2021 12:00:00: Valheim version:0.148.6\n",
+		"04/10/2021 12:35:00: Valheim version:0.148.7\n",
+	} {
+		w, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(line)
+		w.Close()
+		if got := waitEvents(t, events, 1); got[0].Event != ValheimVersion {
+			t.Errorf("event = %+v, want ValheimVersion", got[0])
+		}
+	}
+	expectNoEvent(t, events)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("PipeSource#Watch returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("PipeSource#Watch did not stop after the context was canceled")
+	}
+}
+
+func Test_PipeSource_NotPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, nil, 0644)
+
+	if err := (&PipeSource{Path: path}).Watch(context.Background(), NewWatcher(), func(VHLogEvent) {}); err == nil {
+		t.Error("PipeSource#Watch(regular file) did not return an error")
+	}
+}
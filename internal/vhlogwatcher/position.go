@@ -0,0 +1,73 @@
+package vhlogwatcher
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+// Position is the position of a line in the log file.
+type Position struct {
+	File   string // path to the log file.
+	Head   string // first console log line of the file, it identifies the file even if it has been rotated.
+	Offset int64  // byte offset just after the line.
+}
+
+// ReadHead returns the first console log line of the log file.
+// If the file has no console log line, it returns zero-string.
+func ReadHead(logpath string) (string, error) {
+	file, err := openLog(logpath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if row := strings.TrimSpace(scanner.Text()); reConsoleLog.MatchString(row) {
+			return row, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// ResumeOffsets returns the offsets to resume reading each of logpaths from.
+// logpaths must be sorted in chronological order.
+// A negative offset means that the file has already been consumed.
+//
+// The file which has the same head as the checkpoint is resumed from the
+// checkpoint offset, the files before it are skipped, and the files after it
+// are read from the beginning. If no file has the head (e.g. it has been
+// removed by the log rotation), only the files modified after since are read.
+// If head is zero-string, all files are read from the beginning.
+func ResumeOffsets(logpaths []string, head string, offset int64, since time.Time) []int64 {
+	offsets := make([]int64, len(logpaths))
+	if head == "" {
+		return offsets
+	}
+
+	for i, p := range logpaths {
+		// the size of a compressed file is not of the decompressed lines.
+		info, err := os.Stat(p)
+		if err != nil || (info.Size() < offset && !IsCompressed(p)) {
+			continue
+		}
+		if h, err := ReadHead(p); err != nil || h != head {
+			continue
+		}
+
+		for j := 0; j < i; j++ {
+			offsets[j] = -1
+		}
+		offsets[i] = offset
+		return offsets
+	}
+
+	for i, p := range logpaths {
+		if info, err := os.Stat(p); err == nil && !info.ModTime().After(since) {
+			offsets[i] = -1
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,459 @@
+package vhlogwatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule is a pattern of the log lines and the event generated from them.
+//
+// The fields of the event are taken from the capture groups of the pattern.
+// A group is referred by its number (e.g. "2") or name (e.g. "id"), or by a
+// template with the references (e.g. "server ${mine}, client ${remote}",
+// see regexp.Regexp.Expand).
+type Rule struct {
+	Pattern string `json:"pattern"`
+
+	// Event is the identifier of a built-in event (see ParseEventType), or
+	// the name of a custom event (see RegisterEventType).
+	Event string `json:"event"`
+
+	// Fields maps the fields of the event to the capture groups:
+	//
+	//	value     ... VHLogEvent.Value.
+	//	player_id ... ID of the player, see ParsePlayerID.
+	//	name      ... character name.
+	//	player    ... ID of the player, or the character name if it is not an ID.
+	//	mod       ... name of the mod.
+	//	mod_version
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Timestamp is the capture group of the timestamp, "1" if empty.
+	// NoTimestamp ("-") means that the line has no timestamp, and the time of
+	// the previous line is used.
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// TimeLayout is the layout of the timestamp (see time.Parse).
+	// The layout of the console log is used if empty.
+	TimeLayout string `json:"time_layout,omitempty"`
+}
+
+// Fields of the events which can be mapped by the rules.
+const (
+	FieldValue      = "value"
+	FieldPlayerID   = "player_id"
+	FieldName       = "name"
+	FieldPlayer     = "player"
+	FieldMod        = "mod"
+	FieldModVersion = "mod_version"
+)
+
+var ruleFields = []string{FieldValue, FieldPlayerID, FieldName, FieldPlayer, FieldMod, FieldModVersion}
+
+// NoTimestamp is Rule.Timestamp of the lines without the timestamp.
+const NoTimestamp = "-"
+
+// consoleLogTimeLayout is the layout of the timestamp of the console log.
+const consoleLogTimeLayout = "01/02/2006 15:04:05"
+
+// logPrefix matches the timestamp at the beginning of a console log line.
+const logPrefix = `^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): `
+
+// bepInExPrefix matches the level and the source of a BepInEx log line,
+// e.g. "[Info   :   BepInEx] ". BepInEx does not write the timestamp.
+const bepInExPrefix = `^\[\w+ *: *BepInEx *\] `
+
+// bepInExPlugin matches the name and the version of a plugin in a BepInEx log
+// line, e.g. "[Valheim Plus 0.9.9.11]".
+const bepInExPlugin = `\[(.+) ([^ \]]+)\]`
+
+// DefaultRules are the built-in rules. The rules loaded by SetRules are tried
+// before them, so that a rule can override the wording of a built-in one.
+var DefaultRules = []Rule{
+	//-------------------------------------------------------------------------
+	// Server event
+	//-------------------------------------------------------------------------
+	{
+		Event:   "ValheimVersion",
+		Pattern: logPrefix + `Valheim version:([0-9\.]+)$`,
+		Fields:  map[string]string{FieldValue: "2"}, // valheim version
+	},
+	{
+		Event:   "ServerID",
+		Pattern: logPrefix + `Server ID (\d+)$`,
+		Fields:  map[string]string{FieldValue: "2"}, // server id
+	},
+	{
+		Event:   "InitWorldGenSeed",
+		Pattern: logPrefix + `Initializing world generator seed:([a-zA-Z0-9]{10}) .*`,
+		Fields:  map[string]string{FieldValue: "2"}, // seed value of world
+	},
+	{
+		Event:   "LoadWorld",
+		Pattern: logPrefix + `Load world (.*)$`,
+		Fields:  map[string]string{FieldValue: "2"}, // world name
+	},
+	{
+		Event:   "GameServerConnected",
+		Pattern: logPrefix + `Game server connected$`,
+	},
+	{
+		Event:   "GameServerConnectedFailed",
+		Pattern: logPrefix + `Game server connected failed$`,
+	},
+	{
+		Event:   "GameServerDisconnected",
+		Pattern: logPrefix + `Game server disconnected$`,
+	},
+	{
+		Event:   "DayHasPassed",
+		Pattern: logPrefix + `Time ([0-9\.]{16}), day:([0-9]*)\ *nextm:([0-9\.]{16})  skipspeed:([0-9.]{16})`,
+		Fields:  map[string]string{FieldValue: "3"}, // day
+	},
+	{ // Global key has been set, e.g. "defeated_eikthyr".
+		Event:   "WorldProgression",
+		Pattern: logPrefix + `Setting global key (\S+)$`,
+		Fields:  map[string]string{FieldValue: "2"},
+	},
+	{ // World saved, e.g. "World saved ( 1245.132ms )".
+		Event:   "WorldSaved",
+		Pattern: logPrefix + `World saved \( *([0-9\.]+) *ms *\)$`,
+		Fields:  map[string]string{FieldValue: "2"}, // duration in milliseconds.
+	},
+	{ // Number of the objects saved with the world, e.g. "Saved 123456 zdos".
+		Event:   "WorldSize",
+		Pattern: logPrefix + `Sav(?:ed|ing) (\d+) (?i:zdos)$`,
+		Fields:  map[string]string{FieldValue: "2"},
+	},
+	{ // Random event (raid) has started, e.g. "Random event set:army_eikthyr".
+		Event:   "RandomEvent",
+		Pattern: logPrefix + `Random event set: ?(\w+)$`,
+		Fields:  map[string]string{FieldValue: "2"}, // name of the event.
+	},
+
+	//-------------------------------------------------------------------------
+	// User event
+	//-------------------------------------------------------------------------
+	{
+		Event:   "Connection",
+		Pattern: logPrefix + `Got connection SteamID (\d+)$`,
+		Fields:  map[string]string{FieldPlayerID: "2"},
+	},
+	{
+		Event:   "GotHandshake",
+		Pattern: logPrefix + `Got handshake from client ` + playerIDPattern + `$`,
+		Fields:  map[string]string{FieldPlayerID: "2"},
+	},
+	{ // Handshake with the crossplay, the remote ID is used by the later lines.
+		Event:   "GotHandshake",
+		Pattern: logPrefix + `PlayFab socket with remote ID ` + playerIDPattern + ` received local Platform ID ` + playerIDPattern + `$`,
+		Fields:  map[string]string{FieldPlayerID: "3", FieldValue: "2"},
+	},
+	{
+		Event:   "Disconnection",
+		Pattern: logPrefix + `Closing socket ` + playerIDPattern + `$`,
+		Fields:  map[string]string{FieldPlayerID: "2"},
+	},
+	{ // ZDOID "0:0" means that the character has just died.
+		Event:   "PlayerDeath",
+		Pattern: logPrefix + `Got character ZDOID from (.+?) : 0:0$`,
+		Fields:  map[string]string{FieldName: "2"},
+	},
+	{ // The name may contain any letters and spaces, e.g. "Björn Ironside".
+		Event:   "GotCharacter",
+		Pattern: logPrefix + `Got character ZDOID from (.+?) : -?\d+:\d+$`,
+		Fields:  map[string]string{FieldName: "2"},
+	},
+
+	//-------------------------------------------------------------------------
+	// Connection failure
+	//-------------------------------------------------------------------------
+	{
+		Event:   "WrongPassword",
+		Pattern: logPrefix + `Peer ` + playerIDPattern + ` has wrong password$`,
+		Fields:  map[string]string{FieldPlayerID: "2"},
+	},
+	{ // e.g. "Peer 123 has incompatible version, mine:0.148.6 remote 0.147.3"
+		Event:   "IncompatibleVersion",
+		Pattern: logPrefix + `Peer ` + playerIDPattern + ` has incompatible version, mine:(\S+) remote:? ?(\S+)$`,
+		Fields:  map[string]string{FieldPlayerID: "2", FieldValue: "server ${3}, client ${4}"},
+	},
+	{ // Banned, or not in the whitelist.
+		Event:   "Banned",
+		Pattern: logPrefix + `(?:Player|Peer) ` + playerIDPattern + ` is (banned|blacklisted or not in whitelist)\.?$`,
+		Fields:  map[string]string{FieldPlayerID: "2", FieldValue: "3"},
+	},
+	{ // Kicked by an admin, with the player ID or the character name.
+		Event:   "Kicked",
+		Pattern: logPrefix + `Kicking (?:player |user )?(.+)$`,
+		Fields:  map[string]string{FieldPlayer: "2"},
+	},
+
+	//-------------------------------------------------------------------------
+	// Mod (BepInEx)
+	//-------------------------------------------------------------------------
+	{ // e.g. "BepInEx 5.4.21.0 - valheim_server (3/20/2023 12:00:00 PM)"
+		Event:     "ModFramework",
+		Pattern:   bepInExPrefix + `(BepInEx) (\d[\w\.]*) - `,
+		Fields:    map[string]string{FieldMod: "1", FieldModVersion: "2"},
+		Timestamp: NoTimestamp,
+	},
+	{
+		Event:     "ModLoaded",
+		Pattern:   bepInExPrefix + `Loading ` + bepInExPlugin + `$`,
+		Fields:    map[string]string{FieldMod: "1", FieldModVersion: "2"},
+		Timestamp: NoTimestamp,
+	},
+	{ // e.g. "Could not load [X 1.0.0] because it has missing dependencies: Jotunn"
+		Event:     "ModError",
+		Pattern:   bepInExPrefix + `(?:Could not load|Skipping) ` + bepInExPlugin + ` because (.+)$`,
+		Fields:    map[string]string{FieldMod: "1", FieldModVersion: "2", FieldValue: "3"},
+		Timestamp: NoTimestamp,
+	},
+	{ // an exception has been thrown while loading the plugin.
+		Event:     "ModError",
+		Pattern:   bepInExPrefix + `Error loading ` + bepInExPlugin + ` ?: ?(.+)$`,
+		Fields:    map[string]string{FieldMod: "1", FieldModVersion: "2", FieldValue: "3"},
+		Timestamp: NoTimestamp,
+	},
+}
+
+// RuleError is an error of a rule.
+type RuleError struct {
+	Index int    // index of the rule.
+	Event string // event of the rule.
+	Key   string // key of the rule, e.g. "pattern", "fields.value".
+	Err   error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule[%d] (%s): %s: %v", e.Index, e.Event, e.Key, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+type compiledRule struct {
+	re        *regexp.Regexp
+	event     EventType
+	fields    map[string]string // field -> template.
+	timestamp string            // template.
+	layout    string
+}
+
+// reTemplateRef matches the references to the capture groups in a template.
+var reTemplateRef = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// toTemplate returns the template of the group reference.
+func toTemplate(ref string) string {
+	if strings.Contains(ref, "$") {
+		return ref
+	}
+	return "${" + ref + "}"
+}
+
+// checkTemplate returns an error if the template refers to an unknown group.
+func checkTemplate(re *regexp.Regexp, tmpl string) error {
+	for _, m := range reTemplateRef.FindAllStringSubmatch(strings.ReplaceAll(tmpl, "$$", ""), -1) {
+		ref := m[1] + m[2]
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n > re.NumSubexp() {
+				return fmt.Errorf("no capture group %d in the pattern", n)
+			}
+		} else if re.SubexpIndex(ref) < 0 {
+			return fmt.Errorf("no capture group %q in the pattern", ref)
+		}
+	}
+	return nil
+}
+
+func compileRule(i int, rule Rule) (compiledRule, error) {
+	ruleErr := func(key string, err error) error {
+		return &RuleError{Index: i, Event: rule.Event, Key: key, Err: err}
+	}
+
+	if rule.Pattern == "" {
+		return compiledRule{}, ruleErr("pattern", fmt.Errorf("must not be empty"))
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return compiledRule{}, ruleErr("pattern", err)
+	}
+
+	et, ok := ParseEventType(rule.Event)
+	if !ok {
+		if et, err = RegisterEventType(rule.Event); err != nil {
+			return compiledRule{}, ruleErr("event", err)
+		}
+	}
+
+	cr := compiledRule{
+		re:        re,
+		event:     et,
+		fields:    make(map[string]string, len(rule.Fields)),
+		timestamp: toTemplate("1"),
+		layout:    consoleLogTimeLayout,
+	}
+	for field, ref := range rule.Fields {
+		if !contains(ruleFields, field) {
+			return compiledRule{}, ruleErr("fields."+field, fmt.Errorf("unknown field, must be one of %s", strings.Join(ruleFields, ", ")))
+		}
+		cr.fields[field] = toTemplate(ref)
+		if err := checkTemplate(re, cr.fields[field]); err != nil {
+			return compiledRule{}, ruleErr("fields."+field, err)
+		}
+	}
+	if (et.IsUserEvent() || et.IsConnectionFailure()) && cr.fields[FieldPlayerID] == "" && cr.fields[FieldName] == "" && cr.fields[FieldPlayer] == "" {
+		return compiledRule{}, ruleErr("fields", fmt.Errorf("a user event needs %s, %s or %s", FieldPlayerID, FieldName, FieldPlayer))
+	}
+
+	switch rule.Timestamp {
+	case "":
+	case NoTimestamp:
+		cr.timestamp = ""
+	default:
+		cr.timestamp = toTemplate(rule.Timestamp)
+	}
+	if err := checkTemplate(re, cr.timestamp); err != nil {
+		return compiledRule{}, ruleErr("timestamp", err)
+	}
+	if rule.TimeLayout != "" {
+		cr.layout = rule.TimeLayout
+	}
+	return cr, nil
+}
+
+// match returns the event of the line, or false if the line does not match.
+func (cr compiledRule) match(line string) (VHLogEvent, bool) {
+	m := cr.re.FindStringSubmatchIndex(line)
+	if m == nil {
+		return VHLogEvent{}, false
+	}
+	expand := func(tmpl string) string {
+		return string(cr.re.ExpandString(nil, tmpl, line, m))
+	}
+
+	// the time of the line without the timestamp is filled by the reader.
+	event := VHLogEvent{Event: cr.event}
+	if cr.timestamp != "" {
+		event.Timestamp = parseLogTime(cr.layout, expand(cr.timestamp))
+	}
+	if tmpl, ok := cr.fields[FieldValue]; ok {
+		event.Value = expand(tmpl)
+	}
+	if tmpl, ok := cr.fields[FieldMod]; ok {
+		event.Mod = strings.TrimSpace(expand(tmpl))
+	}
+	if tmpl, ok := cr.fields[FieldModVersion]; ok {
+		event.ModVersion = expand(tmpl)
+	}
+	if tmpl, ok := cr.fields[FieldPlayerID]; ok {
+		event = withPlayerID(event, expand(tmpl))
+	}
+	if tmpl, ok := cr.fields[FieldName]; ok {
+		if event.Name = sanitizeName(expand(tmpl)); event.Name == "" {
+			return VHLogEvent{Event: None}, true
+		}
+	}
+	if tmpl, ok := cr.fields[FieldPlayer]; ok {
+		player := expand(tmpl)
+		if _, _, ok := ParsePlayerID(player); ok {
+			event = withPlayerID(event, player)
+		} else if event.Name = sanitizeName(player); event.Name == "" {
+			return VHLogEvent{Event: None}, true
+		}
+	}
+	return normalizeEvent(event), true
+}
+
+// normalizeEvent converts the values of the built-in events, so that they are
+// the same whichever rule has matched.
+func normalizeEvent(event VHLogEvent) VHLogEvent {
+	switch event.Event {
+	case WorldProgression:
+		// the case of the global keys has changed across the versions.
+		event.Value = strings.ToLower(event.Value)
+	case GotHandshake:
+		// the remote ID of a crossplay socket.
+		if id, _, ok := ParsePlayerID(event.Value); ok {
+			event.Value = id
+		}
+	}
+	return event
+}
+
+// compileRules compiles the rules, or returns the first error.
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	ret := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		cr, err := compileRule(i, rule)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cr)
+	}
+	return ret, nil
+}
+
+func mustCompileRules(rules []Rule) []compiledRule {
+	ret, err := compileRules(rules)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+var defaultRules = mustCompileRules(DefaultRules)
+
+// activeRules are the rules to scan the log lines.
+var activeRules = struct {
+	mu    sync.RWMutex
+	rules []compiledRule
+}{rules: defaultRules}
+
+// SetRules sets the rules which are tried before DefaultRules.
+// The new events of the rules are registered as the custom events.
+// If a rule is invalid, the rules are not changed and a *RuleError is returned.
+func SetRules(rules []Rule) error {
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return err
+	}
+
+	activeRules.mu.Lock()
+	defer activeRules.mu.Unlock()
+
+	activeRules.rules = append(compiled, defaultRules...)
+	return nil
+}
+
+// LoadRules reads the rules from the JSON file, which is an array of Rule.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := compileRules(rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// parseLogTime parses the timestamp of the log line.
+// The console log is written in the local time of the server.
+func parseLogTime(layout, logtime string) time.Time {
+	t, _ := time.ParseInLocation(layout, logtime, time.Local)
+	return t
+}
@@ -0,0 +1,159 @@
+package vhlogwatcher
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SetRules(t *testing.T) {
+	t.Cleanup(func() { SetRules(nil) })
+
+	err := SetRules([]Rule{
+		{ // the wording of a built-in event has changed.
+			Event:   "GotCharacter",
+			Pattern: logPrefix + `Character (?P<name>.+) has joined$`,
+			Fields:  map[string]string{FieldName: "name"},
+		},
+		{ // custom event with a player.
+			Event:   "Portal used",
+			Pattern: logPrefix + `Peer ` + playerIDPattern + ` used portal (\w+)$`,
+			Fields:  map[string]string{FieldPlayerID: "2", FieldValue: "tag: $3"},
+		},
+		{ // custom event with another timestamp format.
+			Event:      "Plugin loaded",
+			Pattern:    `^\[(?P<time>[\d\-: ]+)\] Loaded plugin (?P<plugin>\S+)$`,
+			Fields:     map[string]string{FieldValue: "plugin"},
+			Timestamp:  "time",
+			TimeLayout: "2006-01-02 15:04:05",
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetRules returned an error: %v", err)
+	}
+
+	portalUsed, ok := ParseEventType("Portal used")
+	if !ok || !portalUsed.IsCustom() || portalUsed.IsUserEvent() || portalUsed.IsServerEvent() {
+		t.Fatalf("ParseEventType(%q) = (%v, %t), want a custom event", "Portal used", portalUsed, ok)
+	}
+	pluginLoaded, _ := ParseEventType("Plugin loaded")
+
+	wantTime := time.Date(2021, 4, 10, 12, 34, 56, 0, time.Local)
+	cases := []struct {
+		row  string
+		want VHLogEvent
+	}{
+		{
+			"04/10/2021 12:34:56: Character Björn has joined",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "Björn"},
+		},
+		{
+			// the built-in rules are still used.
+			"04/10/2021 12:34:56: Got character ZDOID from Björn : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "Björn"},
+		},
+		{
+			"04/10/2021 12:34:56: Peer Xbox_2535400000000001 used portal home",
+			VHLogEvent{Event: portalUsed, Timestamp: wantTime, SteamID: "Xbox_2535400000000001", Platform: PlatformXbox, Value: "tag: home"},
+		},
+		{
+			"[2021-04-10 12:34:56] Loaded plugin ValheimPlus",
+			VHLogEvent{Event: pluginLoaded, Timestamp: wantTime, Value: "ValheimPlus"},
+		},
+	}
+	for i, c := range cases {
+		if got := scanLogLine(c.row); got != c.want {
+			t.Errorf("scanLogLine(case[%d]) = %+v, want %+v", i, got, c.want)
+		}
+	}
+
+	if got := portalUsed.String(); got != "Portal used" {
+		t.Errorf("String() of the custom event = %q, want %q", got, "Portal used")
+	}
+	if text, _ := portalUsed.MarshalText(); string(text) != "Portal used" {
+		t.Errorf("MarshalText() of the custom event = %q, want %q", text, "Portal used")
+	}
+
+	// the custom events are forgotten by the rules, not by the registry.
+	SetRules(nil)
+	if got := scanLogLine(cases[2].row); got.Event != None {
+		t.Errorf("scanLogLine after SetRules(nil) = %+v, want None", got)
+	}
+	if et, ok := ParseEventType("Portal used"); !ok || et != portalUsed {
+		t.Errorf("ParseEventType(%q) after SetRules(nil) = (%v, %t), want (%v, true)", "Portal used", et, ok, portalUsed)
+	}
+}
+
+func Test_SetRules_Error(t *testing.T) {
+	t.Cleanup(func() { SetRules(nil) })
+
+	cases := []struct {
+		rule    Rule
+		wantKey string
+		wantErr string
+	}{
+		{Rule{Event: "Custom"}, "pattern", "must not be empty"},
+		{Rule{Event: "Custom", Pattern: `(unclosed`}, "pattern", "missing closing )"},
+		{Rule{Pattern: logPrefix + `x$`}, "event", "invalid event name"},
+		{Rule{Event: "None", Pattern: logPrefix + `x$`}, "event", "invalid event name"},
+		{Rule{Event: "Custom", Pattern: logPrefix + `(x)$`, Fields: map[string]string{"steam": "2"}}, "fields.steam", "unknown field"},
+		{Rule{Event: "Custom", Pattern: logPrefix + `(x)$`, Fields: map[string]string{FieldValue: "3"}}, "fields.value", "no capture group 3"},
+		{Rule{Event: "Custom", Pattern: logPrefix + `(x)$`, Fields: map[string]string{FieldValue: "id"}}, "fields.value", `no capture group "id"`},
+		{Rule{Event: "Custom", Pattern: logPrefix + `(x)$`, Fields: map[string]string{FieldValue: "$2 and $3"}}, "fields.value", "no capture group 3"},
+		{Rule{Event: "GotCharacter", Pattern: logPrefix + `(x)$`, Fields: map[string]string{FieldValue: "2"}}, "fields", "a user event needs"},
+		{Rule{Event: "Custom", Pattern: `^x$`}, "timestamp", "no capture group 1"},
+		{Rule{Event: "Custom", Pattern: logPrefix + `x$`, Timestamp: "time"}, "timestamp", `no capture group "time"`},
+	}
+
+	for i, c := range cases {
+		// the valid rule before the invalid one must not be set.
+		err := SetRules([]Rule{{Event: "Custom", Pattern: logPrefix + `valid$`}, c.rule})
+
+		var re *RuleError
+		if !errors.As(err, &re) {
+			t.Errorf("SetRules(case[%d]) = %v, want *RuleError", i, err)
+			continue
+		}
+		if re.Index != 1 || re.Key != c.wantKey || !strings.Contains(re.Error(), c.wantErr) {
+			t.Errorf("SetRules(case[%d]) = %v, want rule[1] %s: %s", i, err, c.wantKey, c.wantErr)
+		}
+		if got := scanLogLine("04/10/2021 12:34:56: valid"); got.Event != None {
+			t.Errorf("scanLogLine after SetRules(case[%d]) = %+v, want None", i, got)
+		}
+	}
+}
+
+func Test_LoadRules(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	ioutil.WriteFile(valid, []byte(`[
+		{"event": "Portal used", "pattern": "^(\\d{2}/\\d{2}/\\d{4} \\d{2}:\\d{2}:\\d{2}): Portal (\\w+)$", "fields": {"value": "2"}}
+	]`), 0644)
+	rules, err := LoadRules(valid)
+	if err != nil {
+		t.Fatalf("LoadRules(valid) returned an error: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Event != "Portal used" || rules[0].Fields[FieldValue] != "2" {
+		t.Errorf("LoadRules(valid) = %+v", rules)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	ioutil.WriteFile(invalid, []byte(`[{"event": "Portal used", "pattern": "(x"}]`), 0644)
+	if _, err := LoadRules(invalid); err == nil || !strings.Contains(err.Error(), "invalid.json: rule[0] (Portal used): pattern:") {
+		t.Errorf("LoadRules(invalid) = %v, want the error of the pattern", err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	ioutil.WriteFile(broken, []byte(`{"event": "Portal used"}`), 0644)
+	if _, err := LoadRules(broken); err == nil || !strings.Contains(err.Error(), "broken.json:") {
+		t.Errorf("LoadRules(broken) = %v, want the error of the JSON", err)
+	}
+
+	if _, err := LoadRules(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("LoadRules(missing) returned no error")
+	}
+}
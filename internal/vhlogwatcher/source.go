@@ -0,0 +1,253 @@
+package vhlogwatcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source is where the output of a Valheim server is read from.
+type Source interface {
+	// Watch calls the callback for each event of the output, until ctx is
+	// done, the output ends or the reading fails. It returns nil if the output
+	// has ended, and ctx.Err() if ctx is done.
+	Watch(ctx context.Context, w *Watcher, callback func(VHLogEvent)) error
+
+	String() string
+}
+
+// FileSource is the log file written by LinuxGSM.
+// It follows the file through the log rotation and never ends.
+type FileSource struct {
+	Path   string
+	Offset int64 // byte offset to start from, negative for the end of the file.
+}
+
+func (s FileSource) Watch(ctx context.Context, w *Watcher, callback func(VHLogEvent)) error {
+	return w.WatchFrom(ctx, s.Path, s.Offset, callback)
+}
+
+func (s FileSource) String() string {
+	return s.Path
+}
+
+// StreamSource is the output written to a reader, e.g. stdin to which
+// `docker logs -f` is piped.
+//
+// A read from the reader can not be canceled, so Watch returns when ctx is
+// done while the read is left blocked until the reader has a line. Watch must
+// not be called again after it has returned, as the reader is still read.
+type StreamSource struct {
+	Name    string
+	Reader  io.Reader
+	Journal bool // the output is of `journalctl -o export`.
+}
+
+// NewStreamSource returns the source of the plain lines written to r.
+func NewStreamSource(name string, r io.Reader) *StreamSource {
+	return &StreamSource{Name: name, Reader: r}
+}
+
+// NewJournalSource returns the source of the journal export format written
+// to r, e.g. by `journalctl -f -o export -u valheim`.
+func NewJournalSource(name string, r io.Reader) *StreamSource {
+	return &StreamSource{Name: name, Reader: r, Journal: true}
+}
+
+func (s *StreamSource) Watch(ctx context.Context, w *Watcher, callback func(VHLogEvent)) error {
+	return w.watchStream(ctx, s.Name, s.Reader, s.Journal, callback)
+}
+
+func (s *StreamSource) String() string {
+	return s.Name
+}
+
+// PipeSource is the output written to a named pipe (FIFO), e.g. by
+// `docker logs -f valheim > /path/to/pipe 2>&1`.
+//
+// The pipe is kept open while no writer has it, so the writer can be
+// restarted without ending the source.
+type PipeSource struct {
+	Path    string
+	Journal bool // the output is of `journalctl -o export`.
+}
+
+func (s *PipeSource) Watch(ctx context.Context, w *Watcher, callback func(VHLogEvent)) error {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s: not a named pipe", s.Path)
+	}
+
+	// open it for writing as well, so that the open does not wait for a
+	// writer and the read does not end when the writer closes it.
+	file, err := os.OpenFile(s.Path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return w.watchStream(ctx, s.Path, file, s.Journal, callback)
+}
+
+func (s *PipeSource) String() string {
+	return s.Path
+}
+
+// streamLine is a line of the output.
+type streamLine struct {
+	text string
+	size int64     // bytes read from the stream for the line.
+	at   time.Time // time of the line given by the stream, zero if unknown.
+}
+
+// watchStream reads the lines of the stream until it ends or ctx is done.
+// The lines are read in another goroutine, because the read from a pipe or
+// stdin does not return until something is written.
+func (w *Watcher) watchStream(ctx context.Context, name string, r io.Reader, journal bool, callback func(VHLogEvent)) error {
+	read := readTextLine
+	if journal {
+		read = readJournalEntry
+	}
+
+	lines := make(chan streamLine)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := read(reader)
+			if line.size > 0 {
+				select {
+				case lines <- line:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	lr := newLogReader(name, 0, w, callback)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case line := <-lines:
+			if !line.at.IsZero() {
+				lr.lastTime = line.at
+			}
+			// a journal message can have several lines, e.g. a stack trace.
+			// The size of the entry is counted with the first one.
+			size := line.size
+			for _, text := range strings.Split(line.text, "\n") {
+				lr.processLine(text, size)
+				size = 0
+			}
+
+		case err := <-errc:
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+// readTextLine reads a plain line of the stream.
+func readTextLine(r *bufio.Reader) (streamLine, error) {
+	text, err := r.ReadString('\n')
+	return streamLine{text: text, size: int64(len(text))}, err
+}
+
+// maxJournalFieldSize is the max size of a binary field. A larger size is
+// taken as a malformed stream rather than allocated.
+const maxJournalFieldSize = 1 << 20
+
+// readJournalEntry reads an entry of the journal export format, and returns
+// its MESSAGE with __REALTIME_TIMESTAMP. The entries without MESSAGE are
+// skipped. A binary MESSAGE may have several lines.
+//
+// An entry is the fields followed by an empty line. A field is "KEY=value\n",
+// or "KEY\n" followed by the size (uint64, little endian), the binary value
+// and "\n" if the value has newlines or control characters.
+// See https://systemd.io/JOURNAL_EXPORT_FORMATS/
+func readJournalEntry(r *bufio.Reader) (streamLine, error) {
+	var line streamLine
+	found := false
+	for {
+		field, err := r.ReadString('\n')
+		line.size += int64(len(field))
+		if err != nil {
+			if err == io.EOF && field != "" {
+				err = io.ErrUnexpectedEOF
+			}
+			if !found {
+				line = streamLine{}
+			}
+			return line, err
+		}
+
+		field = strings.TrimSuffix(field, "\n")
+		if field == "" {
+			if found {
+				return line, nil
+			}
+			line = streamLine{size: line.size}
+			continue
+		}
+
+		key, value := field, ""
+		if i := strings.IndexByte(field, '='); i >= 0 {
+			key, value = field[:i], field[i+1:]
+		} else {
+			var size uint64
+			if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+				return streamLine{}, journalEOF(err)
+			}
+			if size > maxJournalFieldSize {
+				return streamLine{}, fmt.Errorf("malformed journal export: binary field %s is too large (%d bytes)", key, size)
+			}
+			data := make([]byte, size+1) // with the trailing newline.
+			if _, err := io.ReadFull(r, data); err != nil {
+				return streamLine{}, journalEOF(err)
+			}
+			if data[size] != '\n' {
+				return streamLine{}, errors.New("malformed journal export: binary field " + key)
+			}
+			value = string(data[:size])
+			line.size += int64(8 + len(data))
+		}
+
+		switch key {
+		case "MESSAGE":
+			line.text, found = value, true
+		case "__REALTIME_TIMESTAMP":
+			if usec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				line.at = time.Unix(usec/1e6, usec%1e6*1e3)
+			}
+		}
+	}
+}
+
+// journalEOF returns the error of the field cut off by the end of the stream.
+func journalEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
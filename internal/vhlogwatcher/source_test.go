@@ -0,0 +1,162 @@
+package vhlogwatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func watchSourceForTest(t *testing.T, src Source) ([]VHLogEvent, error) {
+	t.Helper()
+
+	var events []VHLogEvent
+	err := src.Watch(context.Background(), NewWatcher(), func(e VHLogEvent) {
+		events = append(events, e)
+	})
+	return events, err
+}
+
+func Test_StreamSource(t *testing.T) {
+	lines := []string{
+		"04/10/2021 12:00:00: Valheim version:0.148.6",
+		"[Info   :   BepInEx] Loading [ValheimPlus 0.9.9]",
+		"04/10/2021 12:00:01: Game server connected", // without the newline.
+	}
+	src := NewStreamSource("stdin", strings.NewReader(strings.Join(lines, "\n")))
+	events, err := watchSourceForTest(t, src)
+	if err != nil {
+		t.Fatalf("StreamSource#Watch returned %v, want nil at the end of the stream", err)
+	}
+
+	want := []EventType{ValheimVersion, ModLoaded, GameServerConnected}
+	if len(events) != len(want) {
+		t.Fatalf("StreamSource#Watch returned %d events, want %d", len(events), len(want))
+	}
+	for i, e := range events {
+		if e.Event != want[i] || e.Position.File != "stdin" || e.Position.Head != lines[0] {
+			t.Errorf("events[%d] = %v %+v, want %v", i, e.Event, e.Position, want[i])
+		}
+	}
+	if !events[1].Timestamp.Equal(events[0].Timestamp) {
+		t.Errorf("the line without timestamp has %v, want the time of the previous line", events[1].Timestamp)
+	}
+}
+
+func Test_StreamSource_Cancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan VHLogEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- NewStreamSource("stdin", r).Watch(ctx, NewWatcher(), func(e VHLogEvent) { events <- e })
+	}()
+
+	io.WriteString(w, "04/10/2021 12:00:01: Game server connected\n")
+	if got := waitEvents(t, events, 1); got[0].Event != GameServerConnected {
+		t.Errorf("event = %+v, want GameServerConnected", got[0])
+	}
+
+	// the read is blocked until the writer writes.
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("StreamSource#Watch returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("StreamSource#Watch did not stop after the context was canceled")
+	}
+}
+
+// journalEntry writes an entry of the journal export format.
+// The field of a []byte value is written in the binary form.
+func journalEntry(buf *bytes.Buffer, fields ...interface{}) {
+	for i := 0; i < len(fields); i += 2 {
+		switch v := fields[i+1].(type) {
+		case string:
+			buf.WriteString(fields[i].(string) + "=" + v + "\n")
+		case []byte:
+			buf.WriteString(fields[i].(string) + "\n")
+			binary.Write(buf, binary.LittleEndian, uint64(len(v)))
+			buf.Write(v)
+			buf.WriteString("\n")
+		}
+	}
+	buf.WriteString("\n")
+}
+
+func Test_JournalSource(t *testing.T) {
+	at := time.Date(2021, 4, 10, 12, 0, 5, 0, time.Local)
+
+	var buf bytes.Buffer
+	journalEntry(&buf,
+		"__CURSOR", "s=1;i=1",
+		"__REALTIME_TIMESTAMP", "1618056000000000",
+		"_SYSTEMD_UNIT", "valheim.service",
+		"MESSAGE", "04/10/2021 12:00:00: Valheim version:0.148.6",
+	)
+	// an entry without the message, e.g. of systemd.
+	journalEntry(&buf, "__CURSOR", "s=1;i=2", "CODE_LINE", "42")
+	// the line without timestamp gets the time of the entry.
+	journalEntry(&buf,
+		"__REALTIME_TIMESTAMP", strconv.FormatInt(at.UnixNano()/1e3, 10),
+		"MESSAGE", []byte("[Error  :   BepInEx] Error loading [Broken 1.0.0] : missing\x1b"),
+	)
+	journalEntry(&buf, "MESSAGE", "04/10/2021 12:00:10: Game server connected")
+	// the lines of a binary message are read one by one.
+	journalEntry(&buf, "MESSAGE", []byte(
+		"04/10/2021 12:01:00: Got connection SteamID 76561198000000001\n"+
+			"04/10/2021 12:01:01: Got handshake from client 76561198000000001\n"))
+
+	events, err := watchSourceForTest(t, NewJournalSource("journal", &buf))
+	if err != nil {
+		t.Fatalf("JournalSource#Watch returned %v", err)
+	}
+	want := []EventType{ValheimVersion, ModError, GameServerConnected, Connection, GotHandshake}
+	if len(events) != len(want) {
+		t.Fatalf("JournalSource#Watch returned %d events %+v, want %d", len(events), events, len(want))
+	}
+	for i, e := range events {
+		if e.Event != want[i] {
+			t.Errorf("events[%d] = %v, want %v", i, e.Event, want[i])
+		}
+	}
+	if events[1].Mod != "Broken" || !events[1].Timestamp.Equal(at) {
+		t.Errorf("the binary message = %+v, want the mod Broken at %v", events[1], at)
+	}
+	if events[2].Timestamp.Equal(at) {
+		t.Errorf("the line with timestamp has the time of the entry")
+	}
+}
+
+func Test_JournalSource_Error(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("MESSAGE\n")
+	binary.Write(&buf, binary.LittleEndian, uint64(100))
+	buf.WriteString("too short")
+
+	if _, err := watchSourceForTest(t, NewJournalSource("journal", &buf)); err == nil {
+		t.Error("JournalSource#Watch(cut off binary field) did not return an error")
+	}
+
+	// the size is not allocated, nor overflows with the trailing newline.
+	for _, size := range []uint64{maxJournalFieldSize + 1, math.MaxUint64} {
+		buf.Reset()
+		buf.WriteString("MESSAGE\n")
+		binary.Write(&buf, binary.LittleEndian, size)
+		buf.WriteString("short\n\n")
+
+		_, err := watchSourceForTest(t, NewJournalSource("journal", &buf))
+		if err == nil || !strings.Contains(err.Error(), "malformed journal export") {
+			t.Errorf("JournalSource#Watch(binary field of %d bytes) returned %v, want the malformed error", size, err)
+		}
+	}
+}
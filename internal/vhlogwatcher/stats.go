@@ -0,0 +1,67 @@
+package vhlogwatcher
+
+import (
+	"sync"
+)
+
+// Stats is the statistics of the log lines read by vhlogwatcher.
+type Stats struct {
+	Events   map[string]uint64 // number of lines parsed, keyed by the event name.
+	Unparsed uint64            // number of lines which are not parsed as any event.
+}
+
+// lineStats counts the log lines. The zero value is ready to use.
+type lineStats struct {
+	mu       sync.Mutex
+	events   map[EventType]uint64
+	unparsed uint64
+}
+
+// stats is of the lines read by all the Watchers.
+var stats lineStats
+
+func (s *lineStats) count(et EventType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if et == None {
+		s.unparsed += 1
+	} else {
+		if s.events == nil {
+			s.events = make(map[EventType]uint64)
+		}
+		s.events[et] += 1
+	}
+}
+
+func (s *lineStats) get() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := Stats{
+		Events:   make(map[string]uint64, len(s.events)),
+		Unparsed: s.unparsed,
+	}
+	for et, n := range s.events {
+		ret.Events[et.String()] = n
+	}
+	return ret
+}
+
+// countLine counts the line read by the Watcher.
+func countLine(w *Watcher, et EventType) {
+	stats.count(et)
+	w.stats.count(et)
+}
+
+// GetStats returns the statistics of the log lines read by all the Watchers
+// since the start.
+func GetStats() Stats {
+	return stats.get()
+}
+
+// Stats returns the statistics of the log lines read by the Watcher since it
+// was created.
+func (w *Watcher) Stats() Stats {
+	return w.stats.get()
+}
@@ -2,13 +2,15 @@ package vhlogwatcher
 
 import (
 	"bufio"
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/hpcloud/tail"
+	"unicode"
 )
 
 type EventType int
@@ -25,12 +27,32 @@ const (
 	GameServerConnectedFailed
 	GameServerDisconnected
 	DayHasPassed
+	WorldProgression // a global key has been set, e.g. a boss has been defeated.
+	WorldSaved
+	WorldSize
+	RandomEvent // a raid has started, e.g. "army_eikthyr".
+
+	// SERVER, mods of the mod framework (BepInEx).
+	ModFramework // the mod framework has started loading the mods.
+	ModLoaded
+	ModError // a mod has failed to load.
 
 	// USER
 	Connection
 	GotHandshake
 	GotCharacter
 	Disconnection
+	PlayerDeath
+
+	// USER, connection failures
+	WrongPassword
+	IncompatibleVersion
+	Banned
+	Kicked
+
+	// the custom events defined by the rules are numbered from here,
+	// see RegisterEventType.
+	firstCustomEvent
 )
 
 func (et EventType) String() string {
@@ -55,6 +77,20 @@ func (et EventType) String() string {
 		return "Game server disconnected"
 	case DayHasPassed:
 		return "DayHasPassed"
+	case WorldProgression:
+		return "World progression"
+	case WorldSaved:
+		return "World saved"
+	case WorldSize:
+		return "World size"
+	case RandomEvent:
+		return "Random event"
+	case ModFramework:
+		return "Mod framework"
+	case ModLoaded:
+		return "Mod loaded"
+	case ModError:
+		return "Mod error"
 
 	// USER
 	case Connection:
@@ -65,166 +101,202 @@ func (et EventType) String() string {
 		return "Got Character"
 	case Disconnection:
 		return "Disconnection"
+	case PlayerDeath:
+		return "Player death"
+	case WrongPassword:
+		return "Wrong password"
+	case IncompatibleVersion:
+		return "Incompatible version"
+	case Banned:
+		return "Banned"
+	case Kicked:
+		return "Kicked"
 	default:
-		return ""
+		return customEventName(et)
+	}
+}
+
+// eventTypeNames maps the identifiers of the event types.
+var eventTypeNames = map[string]EventType{
+	"ValheimVersion":            ValheimVersion,
+	"ServerID":                  ServerID,
+	"InitWorldGenSeed":          InitWorldGenSeed,
+	"LoadWorld":                 LoadWorld,
+	"GameServerConnected":       GameServerConnected,
+	"GameServerConnectedFailed": GameServerConnectedFailed,
+	"GameServerDisconnected":    GameServerDisconnected,
+	"DayHasPassed":              DayHasPassed,
+	"WorldProgression":          WorldProgression,
+	"WorldSaved":                WorldSaved,
+	"WorldSize":                 WorldSize,
+	"RandomEvent":               RandomEvent,
+	"ModFramework":              ModFramework,
+	"ModLoaded":                 ModLoaded,
+	"ModError":                  ModError,
+	"Connection":                Connection,
+	"GotHandshake":              GotHandshake,
+	"GotCharacter":              GotCharacter,
+	"Disconnection":             Disconnection,
+	"PlayerDeath":               PlayerDeath,
+	"WrongPassword":             WrongPassword,
+	"IncompatibleVersion":       IncompatibleVersion,
+	"Banned":                    Banned,
+	"Kicked":                    Kicked,
+}
+
+// ParseEventType returns the event type of the name.
+// The name is either the identifier (e.g. "GotCharacter") or
+// the string representation (e.g. "Got Character").
+func ParseEventType(name string) (EventType, bool) {
+	if et, ok := eventTypeNames[name]; ok {
+		return et, true
+	}
+	for _, et := range eventTypeNames {
+		if et.String() == name {
+			return et, true
+		}
+	}
+	return customEventType(name)
+}
+
+// customEvents are the names of the custom events, indexed by
+// EventType - firstCustomEvent.
+var customEvents = struct {
+	mu    sync.RWMutex
+	names []string
+}{}
+
+// RegisterEventType returns the event type of the custom event, which is
+// registered if it is new. Custom events are neither server nor user events,
+// and they are only recorded by the store.
+//
+// It returns an error if the name is empty or one of the built-in events.
+func RegisterEventType(name string) (EventType, error) {
+	if name == "" || name == None.String() {
+		return None, fmt.Errorf("invalid event name %q", name)
+	}
+	if et, ok := ParseEventType(name); ok && !et.IsCustom() {
+		return None, fmt.Errorf("%q is a built-in event", name)
+	}
+
+	customEvents.mu.Lock()
+	defer customEvents.mu.Unlock()
+
+	for i, n := range customEvents.names {
+		if n == name {
+			return firstCustomEvent + EventType(i), nil
+		}
+	}
+	customEvents.names = append(customEvents.names, name)
+	return firstCustomEvent + EventType(len(customEvents.names)-1), nil
+}
+
+func customEventName(et EventType) string {
+	customEvents.mu.RLock()
+	defer customEvents.mu.RUnlock()
+
+	if i := int(et - firstCustomEvent); i >= 0 && i < len(customEvents.names) {
+		return customEvents.names[i]
+	}
+	return ""
+}
+
+func customEventType(name string) (EventType, bool) {
+	customEvents.mu.RLock()
+	defer customEvents.mu.RUnlock()
+
+	for i, n := range customEvents.names {
+		if n == name {
+			return firstCustomEvent + EventType(i), true
+		}
+	}
+	return None, false
+}
+
+// IsCustom returns true if the event is defined by the rules.
+func (et EventType) IsCustom() bool {
+	return et >= firstCustomEvent
+}
+
+// IsConnectionFailure returns true if the event is a player failing to join
+// or being removed from the server.
+func (et EventType) IsConnectionFailure() bool {
+	switch et {
+	case WrongPassword, IncompatibleVersion, Banned, Kicked:
+		return true
+	}
+	return false
+}
+
+// IsServerEvent returns true if the event is about the server or the world.
+func (et EventType) IsServerEvent() bool {
+	switch et {
+	case ValheimVersion, ServerID, InitWorldGenSeed, LoadWorld,
+		GameServerConnected, GameServerConnectedFailed, GameServerDisconnected,
+		DayHasPassed, WorldProgression, WorldSaved, WorldSize, RandomEvent,
+		ModFramework, ModLoaded, ModError:
+		return true
+	}
+	return false
+}
+
+// IsUserEvent returns true if the event is about a player.
+// The connection failures are not, because they are only for the admins.
+func (et EventType) IsUserEvent() bool {
+	switch et {
+	case Connection, GotHandshake, GotCharacter, Disconnection, PlayerDeath:
+		return true
 	}
+	return false
+}
+
+// MarshalText encodes the event type as its name.
+func (et EventType) MarshalText() ([]byte, error) {
+	return []byte(et.String()), nil
 }
 
 type VHLogEvent struct {
-	Event     EventType
-	Timestamp time.Time
-	Value     string // generic
+	Event     EventType `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     string    `json:"value,omitempty"` // generic
 
 	// User event
-	SteamID string
-	Name    string
+	// SteamID is the ID of the player, see ParsePlayerID for the other platforms.
+	SteamID    string     `json:"steam_id,omitempty"`
+	Platform   string     `json:"platform,omitempty"` // e.g. "Steam", "Xbox".
+	Name       string     `json:"name,omitempty"`
+	Confidence Confidence `json:"confidence,omitempty"` // of the SteamID of GotCharacter.
+
+	// Mod event
+	Mod        string `json:"mod,omitempty"` // name of the mod, e.g. "ValheimPlus".
+	ModVersion string `json:"mod_version,omitempty"`
+
+	// Position of the line in the log file.
+	Position Position `json:"-"`
 }
 
 var reConsoleLog = regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2})`)
-var reVHEvents = []struct {
-	pattern *regexp.Regexp
-	genfunc func([]string) VHLogEvent
-}{
-
-	//-------------------------------------------------------------------------
-	// Server event
-	//-------------------------------------------------------------------------
-	{ // Valheim Version
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Valheim version:([0-9\.]+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     ValheimVersion,
-				Timestamp: parseLogTime(matches[1]),
-				Value:     matches[2], // valheim version
-			}
-		},
-	},
-	{ // Server ID
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Server ID (\d+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     ServerID,
-				Timestamp: parseLogTime(matches[1]),
-				Value:     matches[2], // server id
-			}
-		},
-	},
-	{ // Initialize world generator seed
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Initializing world generator seed:([a-zA-Z0-9]{10}) .*`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     InitWorldGenSeed,
-				Timestamp: parseLogTime(matches[1]),
-				Value:     matches[2], // seed value of world
-			}
-		},
-	},
-	{ // Load world
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Load world (.*)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     LoadWorld,
-				Timestamp: parseLogTime(matches[1]),
-				Value:     matches[2], // world name
-			}
-		},
-	},
-	{ // Game server connected
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Game server connected$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     GameServerConnected,
-				Timestamp: parseLogTime(matches[1]),
-			}
-		},
-	},
-	{ // Game server connected failed
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Game server connected failed$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     GameServerConnectedFailed,
-				Timestamp: parseLogTime(matches[1]),
-			}
-		},
-	},
-	{ // Game server disconnected
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Game server disconnected$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     GameServerDisconnected,
-				Timestamp: parseLogTime(matches[1]),
-			}
-		},
-	},
-	{ // Day has passed.
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Time ([0-9\.]{16}), day:([0-9]*)\ *nextm:([0-9\.]{16})  skipspeed:([0-9.]{16})`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     DayHasPassed,
-				Timestamp: parseLogTime(matches[1]),
-				Value:     matches[3], // day.
-			}
-		},
-	},
-
-	//-------------------------------------------------------------------------
-	// User event
-	//-------------------------------------------------------------------------
-	{ // User connection
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Got connection SteamID (\d+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     Connection,
-				Timestamp: parseLogTime(matches[1]),
-				SteamID:   matches[2],
-			}
-		},
-	},
-	{ // User handshake
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Got handshake from client (\d+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     GotHandshake,
-				Timestamp: parseLogTime(matches[1]),
-				SteamID:   matches[2],
-			}
-		},
-	},
-	{ // User disconnected
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Closing socket (\d+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     Disconnection,
-				Timestamp: parseLogTime(matches[1]),
-				SteamID:   matches[2],
-			}
-		},
-	},
-	{ // User character
-		pattern: regexp.MustCompile(`^(\d{2}\/\d{2}\/\d{4} \d{2}:\d{2}:\d{2}): Got character ZDOID from ([A-Za-z]\w*) : (-?\d+:\d+)$`),
-		genfunc: func(matches []string) VHLogEvent {
-			return VHLogEvent{
-				Event:     GotCharacter,
-				Timestamp: parseLogTime(matches[1]),
-				Name:      matches[2],
-			}
-		},
-	},
-}
 
-func parseLogTime(logtime string) time.Time {
-	t, _ := time.Parse("01/02/2006 15:04:05", logtime)
-	return t
+// sanitizeName returns the character name without the control characters,
+// the invalid UTF-8 sequences and the surrounding spaces.
+func sanitizeName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.TrimSpace(name)
 }
 
 func scanLogLine(row string) VHLogEvent {
-	if !reConsoleLog.MatchString(row) {
-		return VHLogEvent{Event: None}
-	}
+	activeRules.mu.RLock()
+	defer activeRules.mu.RUnlock()
 
-	for _, ev := range reVHEvents {
-		if ret := ev.pattern.FindStringSubmatch(row); ret != nil {
-			return ev.genfunc(ret)
+	for _, rule := range activeRules.rules {
+		if event, ok := rule.match(row); ok {
+			return event
 		}
 	}
 
@@ -232,46 +304,135 @@ func scanLogLine(row string) VHLogEvent {
 	return VHLogEvent{Event: None}
 }
 
-func ReadVHLog(logpath string, callback func(VHLogEvent)) {
-	file, err := os.Open(logpath)
-	if err != nil {
-		log.Fatal(err)
+// logReader converts the lines of a log file to events.
+type logReader struct {
+	pos      Position
+	lastTime time.Time // timestamp of the last line which has it.
+	watcher  *Watcher
+	callback func(VHLogEvent)
+}
+
+func newLogReader(logpath string, offset int64, w *Watcher, callback func(VHLogEvent)) *logReader {
+	head := ""
+	if offset > 0 {
+		head, _ = ReadHead(logpath)
+	}
+	return &logReader{
+		pos:      Position{File: logpath, Head: head, Offset: offset},
+		watcher:  w,
+		callback: callback,
+	}
+}
+
+// processLine processes a line which has the size bytes in the log file.
+func (lr *logReader) processLine(line string, size int64) {
+	lr.pos.Offset += size
+
+	row := strings.TrimSpace(line)
+	if timestamp := reConsoleLog.FindString(row); timestamp != "" {
+		lr.lastTime = parseLogTime(consoleLogTimeLayout, timestamp)
+		if lr.pos.Head == "" {
+			lr.pos.Head = row
+		}
 	}
-	defer file.Close()
 
-	lastPlayer := ""
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		row := strings.TrimSpace(scanner.Text())
-		if event := scanLogLine(row); event.Event != None {
-			if event.Event == GotHandshake {
-				lastPlayer = event.SteamID
-			} else if event.Event == GotCharacter && lastPlayer != "" {
-				event.SteamID = lastPlayer
-				lastPlayer = ""
-			}
-			callback(event)
+	if row == "" {
+		return
+	}
+
+	event := scanLogLine(row)
+	countLine(lr.watcher, event.Event)
+	if event.Event != None {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = lr.timeOfLine()
+		} else {
+			lr.lastTime = event.Timestamp
 		}
+		event = lr.watcher.Correlator.Correlate(event)
+		event.Position = lr.pos
+		lr.callback(event)
+	}
+}
+
+// timeOfLine returns the time of a line without the timestamp (e.g. BepInEx),
+// which is the time of the previous line. If no line has had the timestamp
+// yet, it is the modification time of the log file, so that the lines of an
+// old log file are not taken as new ones.
+func (lr *logReader) timeOfLine() time.Time {
+	if !lr.lastTime.IsZero() {
+		return lr.lastTime
+	}
+	if info, err := os.Stat(lr.pos.File); err == nil {
+		return info.ModTime()
 	}
+	return time.Now()
+}
+
+// Watcher reads the log files of a server.
+// Each server needs its own Watcher, because the connections are correlated
+// across the log files of the server.
+type Watcher struct {
+	Correlator   *Correlator
+	PollInterval time.Duration // zero means DefaultPollInterval.
+
+	stats lineStats // of the lines read by the Watcher.
+}
+
+// NewWatcher returns a Watcher with a new Correlator.
+func NewWatcher() *Watcher {
+	return &Watcher{Correlator: &Correlator{}}
+}
+
+var defaultWatcher = &Watcher{Correlator: DefaultCorrelator}
+
+// ReadVHLog reads the log file, and calls the callback for each event.
+// It returns ctx.Err() if ctx is done before reaching the end of the file.
+func ReadVHLog(ctx context.Context, logpath string, callback func(VHLogEvent)) error {
+	return defaultWatcher.ReadFrom(ctx, logpath, 0, callback)
 }
 
-func WatchVHLog(logpath string, callback func(VHLogEvent)) {
-	t, err := tail.TailFile(logpath, tail.Config{Follow: true})
+// ReadVHLogFrom reads the log file from the byte offset.
+func ReadVHLogFrom(ctx context.Context, logpath string, offset int64, callback func(VHLogEvent)) error {
+	return defaultWatcher.ReadFrom(ctx, logpath, offset, callback)
+}
+
+// ReadFrom is ReadVHLogFrom with the correlator of the Watcher.
+// The compressed log file (.gz or .zst) is decompressed.
+func (w *Watcher) ReadFrom(ctx context.Context, logpath string, offset int64, callback func(VHLogEvent)) error {
+	file, err := openLogAt(logpath, offset)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer file.Close()
+
+	lr := newLogReader(logpath, offset, w, callback)
+	reader := bufio.NewReader(file)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	lastPlayer := ""
-	for line := range t.Lines {
-		row := strings.TrimSpace(line.Text)
-		if event := scanLogLine(row); event.Event != None {
-			if event.Event == GotHandshake {
-				lastPlayer = event.SteamID
-			} else if event.Event == GotCharacter && lastPlayer != "" {
-				event.SteamID = lastPlayer
-				lastPlayer = ""
-			}
-			callback(event)
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lr.processLine(line, int64(len(line)))
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", logpath, err)
 		}
 	}
 }
+
+// WatchVHLog watches the log file, and calls the callback for each event.
+// It blocks until ctx is done or the watching fails.
+// When ctx is done, it stops watching and returns ctx.Err().
+func WatchVHLog(ctx context.Context, logpath string, callback func(VHLogEvent)) error {
+	return defaultWatcher.WatchFrom(ctx, logpath, 0, callback)
+}
+
+// WatchVHLogFrom watches the log file from the byte offset.
+// If the offset is negative, it watches from the end of the file.
+func WatchVHLogFrom(ctx context.Context, logpath string, offset int64, callback func(VHLogEvent)) error {
+	return defaultWatcher.WatchFrom(ctx, logpath, offset, callback)
+}
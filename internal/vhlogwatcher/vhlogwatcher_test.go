@@ -0,0 +1,437 @@
+package vhlogwatcher
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_scanLogLine(t *testing.T) {
+	wantTime := time.Date(2021, 4, 10, 12, 34, 56, 0, time.Local)
+
+	cases := []struct {
+		row  string
+		want VHLogEvent
+	}{
+		{
+			"04/10/2021 12:34:56: Valheim version:0.148.6",
+			VHLogEvent{Event: ValheimVersion, Timestamp: wantTime, Value: "0.148.6"},
+		},
+		{
+			"04/10/2021 12:34:56: Game server connected",
+			VHLogEvent{Event: GameServerConnected, Timestamp: wantTime},
+		},
+		{
+			"04/10/2021 12:34:56: Setting global key defeated_eikthyr",
+			VHLogEvent{Event: WorldProgression, Timestamp: wantTime, Value: "defeated_eikthyr"},
+		},
+		{
+			"04/10/2021 12:34:56: Setting global key Defeated_GDKing",
+			VHLogEvent{Event: WorldProgression, Timestamp: wantTime, Value: "defeated_gdking"},
+		},
+		{
+			"04/10/2021 12:34:56: World saved ( 1245.132ms )",
+			VHLogEvent{Event: WorldSaved, Timestamp: wantTime, Value: "1245.132"},
+		},
+		{
+			"04/10/2021 12:34:56: World saved ( 87ms )",
+			VHLogEvent{Event: WorldSaved, Timestamp: wantTime, Value: "87"},
+		},
+		{
+			"04/10/2021 12:34:56: Saved 123456 zdos",
+			VHLogEvent{Event: WorldSize, Timestamp: wantTime, Value: "123456"},
+		},
+		{
+			"04/10/2021 12:34:56: Random event set:army_eikthyr",
+			VHLogEvent{Event: RandomEvent, Timestamp: wantTime, Value: "army_eikthyr"},
+		},
+		{
+			"04/10/2021 12:34:56: Got connection SteamID 76561198000000001",
+			VHLogEvent{Event: Connection, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: Got handshake from client 76561198000000001",
+			VHLogEvent{Event: GotHandshake, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from player1 : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "player1"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from player1 : 0:0",
+			VHLogEvent{Event: PlayerDeath, Timestamp: wantTime, Name: "player1"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from Björn : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "Björn"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from 山田 太郎 : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "山田 太郎"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from Ольга : 0:0",
+			VHLogEvent{Event: PlayerDeath, Timestamp: wantTime, Name: "Ольга"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from Big Bob  : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "Big Bob"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from a : b : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "a : b"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from <b>\x00bad\xff</b> : -123456:1",
+			VHLogEvent{Event: GotCharacter, Timestamp: wantTime, Name: "<b>bad</b>"},
+		},
+		{
+			"04/10/2021 12:34:56: Got character ZDOID from \x01 : -123456:1",
+			VHLogEvent{Event: None},
+		},
+		{
+			"04/10/2021 12:34:56: Closing socket 76561198000000001",
+			VHLogEvent{Event: Disconnection, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: Peer 76561198000000001 has wrong password",
+			VHLogEvent{Event: WrongPassword, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: Peer 76561198000000001 has incompatible version, mine:0.148.6 remote 0.147.3",
+			VHLogEvent{Event: IncompatibleVersion, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam, Value: "server 0.148.6, client 0.147.3"},
+		},
+		{
+			"04/10/2021 12:34:56: Player 76561198000000001 is banned",
+			VHLogEvent{Event: Banned, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam, Value: "banned"},
+		},
+		{
+			"04/10/2021 12:34:56: Player 76561198000000001 is blacklisted or not in whitelist.",
+			VHLogEvent{Event: Banned, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam, Value: "blacklisted or not in whitelist"},
+		},
+		{
+			"04/10/2021 12:34:56: Kicking 76561198000000001",
+			VHLogEvent{Event: Kicked, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: Kicking player1",
+			VHLogEvent{Event: Kicked, Timestamp: wantTime, Name: "player1"},
+		},
+		{
+			"04/10/2021 12:34:56: Got handshake from client Steam_76561198000000001",
+			VHLogEvent{Event: GotHandshake, Timestamp: wantTime, SteamID: "76561198000000001", Platform: PlatformSteam},
+		},
+		{
+			"04/10/2021 12:34:56: PlayFab socket with remote ID playfab/0123456789ABCDEF received local Platform ID Xbox_2535400000000001",
+			VHLogEvent{Event: GotHandshake, Timestamp: wantTime, SteamID: "Xbox_2535400000000001", Platform: PlatformXbox, Value: "PlayFab_0123456789ABCDEF"},
+		},
+		{
+			"04/10/2021 12:34:56: Closing socket playfab/0123456789ABCDEF",
+			VHLogEvent{Event: Disconnection, Timestamp: wantTime, SteamID: "PlayFab_0123456789ABCDEF", Platform: PlatformPlayFab},
+		},
+		{
+			"04/10/2021 12:34:56: Peer Xbox_2535400000000001 has wrong password",
+			VHLogEvent{Event: WrongPassword, Timestamp: wantTime, SteamID: "Xbox_2535400000000001", Platform: PlatformXbox},
+		},
+		{
+			"04/10/2021 12:34:56: Kicking Big_Bob",
+			VHLogEvent{Event: Kicked, Timestamp: wantTime, Name: "Big_Bob"},
+		},
+		{
+			"[Message:   BepInEx] BepInEx 5.4.21.0 - valheim_server (3/20/2023 12:00:00 PM)",
+			VHLogEvent{Event: ModFramework, Mod: "BepInEx", ModVersion: "5.4.21.0"},
+		},
+		{
+			"[Info   :   BepInEx] Loading [ValheimPlus 0.9.9.11]",
+			VHLogEvent{Event: ModLoaded, Mod: "ValheimPlus", ModVersion: "0.9.9.11"},
+		},
+		{
+			"[Info   :BepInEx] Loading [Better Archery 1.9.8]",
+			VHLogEvent{Event: ModLoaded, Mod: "Better Archery", ModVersion: "1.9.8"},
+		},
+		{
+			"[Error  :   BepInEx] Could not load [Epic Loot 0.9.35] because it has missing dependencies: Jotunn (v2.11.0 or newer)",
+			VHLogEvent{Event: ModError, Mod: "Epic Loot", ModVersion: "0.9.35", Value: "it has missing dependencies: Jotunn (v2.11.0 or newer)"},
+		},
+		{
+			"[Error  :   BepInEx] Error loading [PlanBuild 0.14.0] : Object reference not set to an instance of an object",
+			VHLogEvent{Event: ModError, Mod: "PlanBuild", ModVersion: "0.14.0", Value: "Object reference not set to an instance of an object"},
+		},
+		{
+			"[Warning:   BepInEx] Skipping [Jotunn 2.10.0] because a newer version exists (Jotunn 2.11.0)",
+			VHLogEvent{Event: ModError, Mod: "Jotunn", ModVersion: "2.10.0", Value: "a newer version exists (Jotunn 2.11.0)"},
+		},
+		{
+			"[Info   :   BepInEx] 12 plugins to load",
+			VHLogEvent{Event: None},
+		},
+		{
+			"[Info   :ValheimPlus] Loading [config]",
+			VHLogEvent{Event: None},
+		},
+		{
+			"04/10/2021 12:34:56: Unsupported log line",
+			VHLogEvent{Event: None},
+		},
+		{
+			"not a console log",
+			VHLogEvent{Event: None},
+		},
+	}
+
+	for i, c := range cases {
+		if got := scanLogLine(c.row); got != c.want {
+			t.Errorf("scanLogLine(case[%d]) = %+v, want %+v", i, got, c.want)
+		}
+	}
+}
+
+func Test_Watcher_Stats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte("04/10/2021 12:34:56: Valheim version:0.148.6\nunknown line\n"), 0644)
+
+	w1, w2 := NewWatcher(), NewWatcher()
+	before := GetStats()
+	for _, w := range []*Watcher{w1, w1, w2} {
+		if err := w.ReadFrom(context.Background(), path, 0, func(VHLogEvent) {}); err != nil {
+			t.Fatalf("Watcher#ReadFrom returned %v", err)
+		}
+	}
+
+	// the lines are counted by the Watcher which has read them.
+	if got := w1.Stats(); got.Unparsed != 2 || got.Events[ValheimVersion.String()] != 2 || len(got.Events) != 1 {
+		t.Errorf("w1.Stats() = %+v, want 2 lines of each", got)
+	}
+	if got := w2.Stats(); got.Unparsed != 1 || got.Events[ValheimVersion.String()] != 1 {
+		t.Errorf("w2.Stats() = %+v, want 1 line of each", got)
+	}
+	if got := GetStats().Unparsed - before.Unparsed; got != 3 {
+		t.Errorf("GetStats().Unparsed increased by %d, want 3 of all the Watchers", got)
+	}
+}
+
+func Test_ReadVHLogFrom(t *testing.T) {
+	lines := []string{
+		"Mono path[0] = '/home/vhserver/serverfiles/valheim_server_Data/Managed'",
+		"04/10/2021 12:34:56: Valheim version:0.148.6",
+		"04/10/2021 12:34:57: Got handshake from client 76561198000000001",
+		"04/10/2021 12:34:58: Got character ZDOID from player1 : -123456:1",
+	}
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	DefaultCorrelator.Reset()
+	before := GetStats()
+
+	var events []VHLogEvent
+	if err := ReadVHLog(context.Background(), path, func(e VHLogEvent) {
+		events = append(events, e)
+	}); err != nil {
+		t.Fatalf("ReadVHLog returned %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("ReadVHLog returned %d events, want 3", len(events))
+	}
+
+	after := GetStats()
+	if got := after.Unparsed - before.Unparsed; got != 1 {
+		t.Errorf("GetStats().Unparsed increased by %d, want 1", got)
+	}
+	for _, et := range []EventType{ValheimVersion, GotHandshake, GotCharacter} {
+		if got := after.Events[et.String()] - before.Events[et.String()]; got != 1 {
+			t.Errorf("GetStats().Events[%q] increased by %d, want 1", et.String(), got)
+		}
+	}
+
+	// events[i] comes from lines[i+1].
+	for i, e := range events {
+		if want := linesOffset(lines, i+2); e.Position.Offset != want {
+			t.Errorf("ReadVHLog events[%d].Position.Offset = %d, want %d", i, e.Position.Offset, want)
+		}
+		if e.Position.Head != lines[1] {
+			t.Errorf("ReadVHLog events[%d].Position.Head = %q, want %q", i, e.Position.Head, lines[1])
+		}
+	}
+	if events[2].SteamID != "76561198000000001" || events[2].Confidence != ConfidenceHigh {
+		t.Errorf("ReadVHLog did not correlate the character with the handshake: %+v", events[2])
+	}
+
+	// resume from the second event.
+	events = nil
+	ReadVHLogFrom(context.Background(), path, linesOffset(lines, 3), func(e VHLogEvent) {
+		events = append(events, e)
+	})
+	if len(events) != 1 || events[0].Event != GotCharacter {
+		t.Fatalf("ReadVHLogFrom returned %+v, want only GotCharacter", events)
+	}
+	if events[0].Position.Head != lines[1] {
+		t.Errorf("ReadVHLogFrom Position.Head = %q, want %q", events[0].Position.Head, lines[1])
+	}
+}
+
+func Test_ReadVHLog_NoTimestamp(t *testing.T) {
+	lines := []string{
+		"[Message:   BepInEx] BepInEx 5.4.21.0 - valheim_server (3/20/2023 12:00:00 PM)",
+		"04/10/2021 12:34:56: Valheim version:0.148.6",
+		"[Info   :   BepInEx] Loading [ValheimPlus 0.9.9.11]",
+	}
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	modTime := time.Date(2021, 4, 10, 12, 0, 0, 0, time.Local)
+	os.Chtimes(path, modTime, modTime)
+
+	var events []VHLogEvent
+	if err := NewWatcher().ReadFrom(context.Background(), path, 0, func(e VHLogEvent) {
+		events = append(events, e)
+	}); err != nil {
+		t.Fatalf("ReadFrom returned %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("ReadFrom returned %d events, want 3", len(events))
+	}
+
+	// the line before any timestamp has the modification time of the file,
+	// and the later one has the time of the previous line.
+	wantTimes := []time.Time{modTime, time.Date(2021, 4, 10, 12, 34, 56, 0, time.Local), time.Date(2021, 4, 10, 12, 34, 56, 0, time.Local)}
+	for i, e := range events {
+		if !e.Timestamp.Equal(wantTimes[i]) {
+			t.Errorf("ReadFrom events[%d].Timestamp = %v, want %v", i, e.Timestamp, wantTimes[i])
+		}
+	}
+}
+
+func Test_ReadVHLog_Error(t *testing.T) {
+	err := ReadVHLog(context.Background(), filepath.Join(t.TempDir(), "not-exist.log"), func(VHLogEvent) {})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadVHLog(not exist file) returned %v, want os.ErrNotExist", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte("04/10/2021 12:34:56: Game server connected\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	err = ReadVHLog(ctx, path, func(VHLogEvent) { called = true })
+	if err != context.Canceled || called {
+		t.Errorf("ReadVHLog(canceled) returned %v (callback called: %t), want context.Canceled", err, called)
+	}
+}
+
+func Test_WatchVHLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhserver-console.log")
+	ioutil.WriteFile(path, []byte("04/10/2021 12:34:56: Game server connected\n"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan VHLogEvent, 10)
+	done := make(chan error)
+	go func() {
+		done <- WatchVHLog(ctx, path, func(e VHLogEvent) { events <- e })
+	}()
+
+	waitEvent := func(want EventType) {
+		t.Helper()
+		select {
+		case e := <-events:
+			if e.Event != want {
+				t.Errorf("WatchVHLog event = %v, want %v", e.Event, want)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("WatchVHLog did not call the callback for %v", want)
+		}
+	}
+
+	waitEvent(GameServerConnected)
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("04/10/2021 12:35:00: Game server disconnected\n")
+	file.Close()
+	waitEvent(GameServerDisconnected)
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("WatchVHLog(canceled) returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("WatchVHLog did not stop after the context was canceled")
+	}
+}
+
+// linesOffset returns the offset just after the first n lines.
+func linesOffset(lines []string, n int) int64 {
+	var offset int64
+	for _, line := range lines[:n] {
+		offset += int64(len(line)) + 1
+	}
+	return offset
+}
+
+func Test_ResumeOffsets(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mtime time.Time) string {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+		os.Chtimes(path, mtime, mtime)
+		return path
+	}
+
+	now := time.Now()
+	files := []string{
+		write("vhserver-console-1.log", "04/01/2021 00:00:00: first\n", now.Add(-time.Hour*3)),
+		write("vhserver-console-2.log", "04/02/2021 00:00:00: second\n04/02/2021 00:00:01: x\n", now.Add(-time.Hour*2)),
+		write("vhserver-console.log", "04/03/2021 00:00:00: third\n", now.Add(-time.Hour)),
+	}
+
+	cases := []struct {
+		head   string
+		offset int64
+		since  time.Time
+		want   []int64
+	}{
+		{"", 0, time.Time{}, []int64{0, 0, 0}},
+		{"04/01/2021 00:00:00: first", 27, time.Time{}, []int64{27, 0, 0}},
+		{"04/02/2021 00:00:00: second", 29, time.Time{}, []int64{-1, 29, 0}},
+		{"04/03/2021 00:00:00: third", 10, time.Time{}, []int64{-1, -1, 10}},
+		{"04/03/2021 00:00:00: third", 1000, now.Add(-time.Hour * 2), []int64{-1, -1, 0}}, // truncated
+		{"removed", 10, now.Add(-time.Minute * 150), []int64{-1, 0, 0}},
+	}
+
+	for i, c := range cases {
+		got := ResumeOffsets(files, c.head, c.offset, c.since)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ResumeOffsets(case[%d]) = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func Test_ParseEventType(t *testing.T) {
+	cases := []struct {
+		name   string
+		want   EventType
+		wantOk bool
+	}{
+		{"GotCharacter", GotCharacter, true},
+		{"Got Character", GotCharacter, true},
+		{"InitWorldGenSeed", InitWorldGenSeed, true},
+		{"Initialize world generator seed", InitWorldGenSeed, true},
+		{"PlayerDeath", PlayerDeath, true},
+		{"World progression", WorldProgression, true},
+		{"None", None, false},
+		{"unknown", None, false},
+	}
+
+	for _, c := range cases {
+		got, ok := ParseEventType(c.name)
+		if got != c.want || ok != c.wantOk {
+			t.Errorf("ParseEventType(%q) = (%v, %t), want (%v, %t)", c.name, got, ok, c.want, c.wantOk)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package vhstatus
+
+import (
+	"sort"
+	"time"
+)
+
+// CustomEvent is an occurrence of an event defined by the log rules.
+type CustomEvent struct {
+	Name    string    `json:"name"`
+	Value   string    `json:"value,omitempty"`
+	SteamID string    `json:"steam_id,omitempty"`
+	Player  string    `json:"player,omitempty"` // character name.
+	At      time.Time `json:"at"`
+}
+
+// CustomEventStats is the summary of a custom event.
+type CustomEventStats struct {
+	Name  string      `json:"name"`
+	Count int         `json:"count"`
+	Last  CustomEvent `json:"last"`
+}
+
+func (s CustomEventStats) LastAtAsString() string {
+	return s.Last.At.Format(time.RFC3339)
+}
+
+// RecordCustomEvent counts the custom event, and keeps it as the last one.
+func (vhs *VHStatus) RecordCustomEvent(event CustomEvent) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	if vhs.customEvents == nil {
+		vhs.customEvents = make(map[string]CustomEventStats)
+	}
+	stats := vhs.customEvents[event.Name]
+	stats.Name = event.Name
+	stats.Count += 1
+	stats.Last = event
+	vhs.customEvents[event.Name] = stats
+
+	vhs.updatedAt = time.Now()
+}
+
+// customEventStats returns the stats of the custom events sorted by name.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) customEventStats() []CustomEventStats {
+	ret := make([]CustomEventStats, 0, len(vhs.customEvents))
+	for _, s := range vhs.customEvents {
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
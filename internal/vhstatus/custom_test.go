@@ -0,0 +1,34 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RecordCustomEvent(t *testing.T) {
+	now := time.Now()
+
+	vhs := New()
+	if got := vhs.Params().CustomEvents; got == nil || len(got) != 0 {
+		t.Fatalf("New().Params().CustomEvents = %#v, want empty", got)
+	}
+
+	vhs.RecordCustomEvent(CustomEvent{Name: "Portal used", Value: "home", SteamID: "1", At: now.Add(-time.Minute)})
+	vhs.RecordCustomEvent(CustomEvent{Name: "Plugin loaded", Value: "ValheimPlus", At: now.Add(-time.Minute)})
+	vhs.RecordCustomEvent(CustomEvent{Name: "Portal used", Value: "mine", Player: "player1", At: now})
+
+	got := vhs.Params().CustomEvents
+	if len(got) != 2 || got[0].Name != "Plugin loaded" || got[1].Name != "Portal used" {
+		t.Fatalf("Params().CustomEvents = %+v, want sorted by name", got)
+	}
+	if got[1].Count != 2 || got[1].Last.Value != "mine" || got[1].Last.Player != "player1" || !got[1].Last.At.Equal(now) {
+		t.Errorf("Params().CustomEvents[1] = %+v, want the count and the last event", got[1])
+	}
+
+	// persisted with the snapshot.
+	restored := New()
+	restored.Restore(vhs.Snapshot())
+	if got := restored.Params().CustomEvents; len(got) != 2 || got[1].Count != 2 || got[1].Last.Value != "mine" {
+		t.Errorf("restored Params().CustomEvents = %+v", got)
+	}
+}
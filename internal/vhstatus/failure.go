@@ -0,0 +1,99 @@
+package vhstatus
+
+import (
+	"errors"
+	"time"
+)
+
+// ConnectionFailure is a failure of a player to join the server,
+// or a removal of the player from the server.
+type ConnectionFailure struct {
+	SteamID string    `json:"steam_id"`
+	Name    string    `json:"name,omitempty"`   // character name, if known.
+	Reason  string    `json:"reason"`           // e.g. "Wrong password".
+	Detail  string    `json:"detail,omitempty"` // e.g. the versions of the server and the client.
+	At      time.Time `json:"at"`
+}
+
+// maxConnectionFailures is the number of the recent failures kept per SteamID.
+const maxConnectionFailures = 20
+
+// maxConnectionFailureIDs is the number of the SteamIDs whose failures are
+// kept, so that e.g. the bots guessing the password do not grow the status.
+const maxConnectionFailureIDs = 1000
+
+// AddConnectionFailure records the failure in the ring buffer of the SteamID.
+// If failure.SteamID is zero-value, the player is looked up by failure.Name.
+//
+// If the player is not found, AddConnectionFailure returns an error.
+func (vhs *VHStatus) AddConnectionFailure(failure ConnectionFailure) error {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	if failure.SteamID == "" {
+		if p := vhs.playerByName(failure.Name); p != nil {
+			failure.SteamID = p.SteamID
+		}
+	}
+	if failure.SteamID == "" {
+		return errors.New("player not found: " + failure.Name)
+	}
+	if failure.Name == "" {
+		for i := range vhs.players {
+			if vhs.players[i].SteamID == failure.SteamID {
+				failure.Name = vhs.players[i].Name
+				break
+			}
+		}
+	}
+
+	if vhs.connectionFailures == nil {
+		vhs.connectionFailures = make(map[string][]ConnectionFailure)
+	}
+	failures := vhs.connectionFailures[failure.SteamID]
+	if len(failures) >= maxConnectionFailures {
+		failures = failures[len(failures)-maxConnectionFailures+1:]
+	}
+	vhs.connectionFailures[failure.SteamID] = append(failures, failure)
+	vhs.evictConnectionFailures()
+
+	vhs.updatedAt = time.Now()
+	return nil
+}
+
+// evictConnectionFailures removes the SteamIDs whose newest failure is the
+// oldest, while there are more than maxConnectionFailureIDs.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) evictConnectionFailures() {
+	for len(vhs.connectionFailures) > maxConnectionFailureIDs {
+		oldestID := ""
+		var oldest time.Time
+		for id, failures := range vhs.connectionFailures {
+			at := failures[len(failures)-1].At
+			if oldestID == "" || at.Before(oldest) || (at.Equal(oldest) && id < oldestID) {
+				oldestID, oldest = id, at
+			}
+		}
+		delete(vhs.connectionFailures, oldestID)
+	}
+}
+
+// ConnectionFailures returns the recent failures, newest first, keyed by SteamID.
+// If steamID is not zero-value, only the failures of the SteamID are returned.
+func (vhs *VHStatus) ConnectionFailures(steamID string) map[string][]ConnectionFailure {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	ret := make(map[string][]ConnectionFailure)
+	for id, failures := range vhs.connectionFailures {
+		if steamID != "" && id != steamID {
+			continue
+		}
+		list := make([]ConnectionFailure, 0, len(failures))
+		for i := len(failures) - 1; i >= 0; i-- {
+			list = append(list, failures[i])
+		}
+		ret[id] = list
+	}
+	return ret
+}
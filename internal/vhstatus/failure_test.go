@@ -0,0 +1,82 @@
+package vhstatus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_AddConnectionFailure(t *testing.T) {
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	vhs := New()
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Got Character", Name: "player1", UpdatedAt: t0})
+
+	if err := vhs.AddConnectionFailure(ConnectionFailure{SteamID: "2", Reason: "Wrong password", At: t0}); err != nil {
+		t.Errorf("AddConnectionFailure(unknown SteamID) returned %v", err)
+	}
+	if err := vhs.AddConnectionFailure(ConnectionFailure{Name: "player1", Reason: "Kicked", At: t0.Add(time.Minute)}); err != nil {
+		t.Errorf("AddConnectionFailure(by name) returned %v", err)
+	}
+	if err := vhs.AddConnectionFailure(ConnectionFailure{Name: "unknown", Reason: "Kicked", At: t0}); err == nil {
+		t.Errorf("AddConnectionFailure(unknown name) returned nil")
+	}
+
+	all := vhs.ConnectionFailures("")
+	if len(all) != 2 || len(all["2"]) != 1 || len(all["1"]) != 1 {
+		t.Fatalf("ConnectionFailures(\"\") = %+v", all)
+	}
+	if got := all["1"][0]; got.SteamID != "1" || got.Name != "player1" || got.Reason != "Kicked" {
+		t.Errorf("ConnectionFailures()[1][0] = %+v", got)
+	}
+	if got := vhs.ConnectionFailures("2"); len(got) != 1 || got["2"][0].Reason != "Wrong password" {
+		t.Errorf("ConnectionFailures(2) = %+v", got)
+	}
+	if got := vhs.ConnectionFailures("3"); len(got) != 0 {
+		t.Errorf("ConnectionFailures(3) = %+v", got)
+	}
+
+	// ring buffer, newest first.
+	for i := 0; i < maxConnectionFailures+5; i++ {
+		vhs.AddConnectionFailure(ConnectionFailure{SteamID: "2", Reason: fmt.Sprint(i), At: t0.Add(time.Minute * time.Duration(i))})
+	}
+	failures := vhs.ConnectionFailures("2")["2"]
+	if len(failures) != maxConnectionFailures || failures[0].Reason != fmt.Sprint(maxConnectionFailures+4) ||
+		failures[len(failures)-1].Reason != "5" {
+		t.Errorf("ConnectionFailures(2) has %d failures from %+v to %+v", len(failures), failures[0], failures[len(failures)-1])
+	}
+
+	dst := New()
+	dst.Restore(vhs.Snapshot())
+	if got := dst.ConnectionFailures(""); len(got["2"]) != maxConnectionFailures || len(got["1"]) != 1 {
+		t.Errorf("restored ConnectionFailures = %d, %d", len(got["2"]), len(got["1"]))
+	}
+}
+
+func Test_AddConnectionFailure_EvictSteamIDs(t *testing.T) {
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	// a bot tries the passwords from many SteamIDs.
+	vhs := New()
+	for i := 0; i < maxConnectionFailureIDs+10; i++ {
+		vhs.AddConnectionFailure(ConnectionFailure{SteamID: fmt.Sprint("bot", i), Reason: "Wrong password", At: t0.Add(time.Second * time.Duration(i))})
+	}
+	// the SteamID with a recent failure is kept even if its first one is old.
+	vhs.AddConnectionFailure(ConnectionFailure{SteamID: "bot10", Reason: "Wrong password", At: t0.Add(time.Hour)})
+	vhs.AddConnectionFailure(ConnectionFailure{SteamID: "player", Reason: "Kicked", At: t0.Add(time.Hour * 2)})
+
+	all := vhs.ConnectionFailures("")
+	if len(all) != maxConnectionFailureIDs {
+		t.Fatalf("ConnectionFailures has %d SteamIDs, want %d", len(all), maxConnectionFailureIDs)
+	}
+	for _, id := range []string{"bot10", "player", fmt.Sprint("bot", maxConnectionFailureIDs+9)} {
+		if len(all[id]) == 0 {
+			t.Errorf("ConnectionFailures has no %s", id)
+		}
+	}
+	for _, id := range []string{"bot0", "bot9", "bot11"} {
+		if len(all[id]) != 0 {
+			t.Errorf("ConnectionFailures has %s, want it evicted", id)
+		}
+	}
+}
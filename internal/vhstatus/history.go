@@ -0,0 +1,272 @@
+package vhstatus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// HistoryPoint is the active player count and the server status in a period.
+type HistoryPoint struct {
+	Time        time.Time `json:"time"` // start of the period.
+	MaxPlayers  int       `json:"max_players"`
+	AvgPlayers  float64   `json:"avg_players"`  // time weighted average.
+	OnlineRatio float64   `json:"online_ratio"` // ratio of the time when the server was online.
+}
+
+// HistoryBucket is the accumulated values of a period.
+type HistoryBucket struct {
+	Start         time.Time `json:"start"`
+	Seconds       float64   `json:"seconds"` // recorded time in the period.
+	PlayerSeconds float64   `json:"player_seconds"`
+	OnlineSeconds float64   `json:"online_seconds"`
+	MaxPlayers    int       `json:"max_players"`
+}
+
+// HistorySeries is the buckets of a resolution.
+type HistorySeries struct {
+	Step      time.Duration   `json:"step"`
+	Retention time.Duration   `json:"retention"` // zero means forever.
+	Buckets   []HistoryBucket `json:"buckets"`   // sorted by Start.
+}
+
+// History is the time series of the active player count and the server status.
+// It is recorded per minute, and rolled up per hour and day.
+type History struct {
+	Series []HistorySeries `json:"series"` // sorted by Step.
+
+	// the state since the last record.
+	Since   time.Time `json:"since"`
+	Players int       `json:"players"`
+	Online  bool      `json:"online"`
+}
+
+const day = time.Hour * 24
+
+// maxHistoryPoints is the maximum number of points of a query.
+const maxHistoryPoints = 10000
+
+func newHistory() History {
+	return History{
+		Series: []HistorySeries{
+			{Step: time.Minute, Retention: day * 2},
+			{Step: time.Hour, Retention: day * 90},
+			{Step: day},
+		},
+	}
+}
+
+// bucketStart returns the start of the bucket which contains t.
+// A day starts at midnight in the local time.
+func bucketStart(t time.Time, step time.Duration) time.Time {
+	if step == day {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(step)
+}
+
+func nextBucket(start time.Time, step time.Duration) time.Time {
+	if step == day {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(step)
+}
+
+// bucket returns the bucket which starts at start, the bucket is appended if needed.
+// start must not be before the last bucket.
+func (s *HistorySeries) bucket(start time.Time) *HistoryBucket {
+	if n := len(s.Buckets); n > 0 && s.Buckets[n-1].Start.Equal(start) {
+		return &s.Buckets[n-1]
+	}
+	s.Buckets = append(s.Buckets, HistoryBucket{Start: start})
+	return &s.Buckets[len(s.Buckets)-1]
+}
+
+// accumulate adds the state between from and to.
+func (s *HistorySeries) accumulate(from, to time.Time, players int, online bool) {
+	if s.Retention > 0 && from.Before(to.Add(-s.Retention)) {
+		from = to.Add(-s.Retention)
+	}
+
+	for start := bucketStart(from, s.Step); start.Before(to); start = nextBucket(start, s.Step) {
+		b := s.bucket(start)
+		pfrom, pto := from, nextBucket(start, s.Step)
+		if pfrom.Before(start) {
+			pfrom = start
+		}
+		if to.Before(pto) {
+			pto = to
+		}
+
+		sec := pto.Sub(pfrom).Seconds()
+		b.Seconds += sec
+		b.PlayerSeconds += float64(players) * sec
+		if online {
+			b.OnlineSeconds += sec
+		}
+		if players > b.MaxPlayers {
+			b.MaxPlayers = players
+		}
+	}
+
+	s.prune(to)
+}
+
+// prune removes the buckets older than the retention.
+func (s *HistorySeries) prune(now time.Time) {
+	if s.Retention == 0 {
+		return
+	}
+
+	i := 0
+	for i < len(s.Buckets) && nextBucket(s.Buckets[i].Start, s.Step).Before(now.Add(-s.Retention)) {
+		i += 1
+	}
+	if i > 0 {
+		s.Buckets = append([]HistoryBucket(nil), s.Buckets[i:]...)
+	}
+}
+
+// record records the state at the time.
+func (h *History) record(at time.Time, players int, online bool) {
+	if !h.Since.IsZero() {
+		// the lines of the log may not be in order.
+		if at.Before(h.Since) {
+			at = h.Since
+		}
+		for i := range h.Series {
+			h.Series[i].accumulate(h.Since, at, h.Players, h.Online)
+		}
+	}
+
+	h.Since, h.Players, h.Online = at, players, online
+	for i := range h.Series {
+		s := &h.Series[i]
+		if b := s.bucket(bucketStart(at, s.Step)); players > b.MaxPlayers {
+			b.MaxPlayers = players
+		}
+	}
+}
+
+// copy returns a deep copy of the history.
+func (h History) copy() History {
+	ret := h
+	ret.Series = make([]HistorySeries, len(h.Series))
+	for i, s := range h.Series {
+		ret.Series[i] = s
+		ret.Series[i].Buckets = append([]HistoryBucket(nil), s.Buckets...)
+	}
+	return ret
+}
+
+// query returns the points between from and to.
+// The current state is counted until now.
+func (h History) query(from, to time.Time, step time.Duration, now time.Time) ([]HistoryPoint, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if step < time.Minute || step%time.Minute != 0 {
+		return nil, fmt.Errorf("step must be a multiple of 1m: %v", step)
+	}
+	if to.Sub(from)/step > maxHistoryPoints {
+		return nil, fmt.Errorf("too many points, the step must be longer than %v", to.Sub(from)/maxHistoryPoints)
+	}
+
+	// the coarsest series which can make the step, as the coarser series are
+	// kept longer. A step which only the minutes can make (e.g. 15m) has no
+	// point older than their retention (2 days), even if the hours have it.
+	var series HistorySeries
+	for i := len(h.Series) - 1; i >= 0; i-- {
+		if step%h.Series[i].Step == 0 {
+			series = h.Series[i]
+			break
+		}
+	}
+	// count the current state on a copy.
+	series.Buckets = append([]HistoryBucket(nil), series.Buckets...)
+	if !h.Since.IsZero() && now.After(h.Since) {
+		series.accumulate(h.Since, now, h.Players, h.Online)
+	}
+
+	n := int(step / series.Step)
+	origin := bucketStart(from, series.Step)
+	if series.Step != day {
+		origin = from.Truncate(step)
+	}
+	groupOf := func(start time.Time) int {
+		if series.Step == day {
+			// a day may not be 24 hours in the local time.
+			return int(math.Round(start.Sub(origin).Hours()/24)) / n
+		}
+		return int(start.Sub(origin) / step)
+	}
+	groupStart := func(g int) time.Time {
+		if series.Step == day {
+			return origin.AddDate(0, 0, g*n)
+		}
+		return origin.Add(step * time.Duration(g))
+	}
+
+	points := make([]HistoryPoint, 0)
+	var acc HistoryBucket
+	group := -1
+	flush := func() {
+		if group < 0 || acc.Seconds == 0 && acc.MaxPlayers == 0 {
+			return
+		}
+		p := HistoryPoint{Time: groupStart(group), MaxPlayers: acc.MaxPlayers}
+		if acc.Seconds > 0 {
+			p.AvgPlayers = acc.PlayerSeconds / acc.Seconds
+			p.OnlineRatio = acc.OnlineSeconds / acc.Seconds
+		}
+		points = append(points, p)
+	}
+
+	for _, b := range series.Buckets {
+		if b.Start.Before(origin) || !b.Start.Before(to) {
+			continue
+		}
+		if g := groupOf(b.Start); g != group {
+			flush()
+			group, acc = g, HistoryBucket{}
+		}
+		acc.Seconds += b.Seconds
+		acc.PlayerSeconds += b.PlayerSeconds
+		acc.OnlineSeconds += b.OnlineSeconds
+		if b.MaxPlayers > acc.MaxPlayers {
+			acc.MaxPlayers = b.MaxPlayers
+		}
+	}
+	flush()
+
+	return points, nil
+}
+
+// RecordHistory records the active player count and the server status at the
+// time of the log event. It should be called after applying every log event,
+// so that the history is rebuilt from the past logs.
+func (vhs *VHStatus) RecordHistory(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.history.record(at, vhs.activePlayerCount, vhs.status == "Online")
+}
+
+// History returns the time series between from and to with the step.
+// The step must be a multiple of a minute, and its points are made from the
+// coarsest resolution which can make it, e.g. 15m from the minutes, 6h from
+// the hours, 2d from the days. The periods which have no record are omitted,
+// so a step of minutes returns nothing for the range older than 2 days, which
+// the minutes are kept for.
+func (vhs *VHStatus) History(from, to time.Time, step time.Duration) ([]HistoryPoint, error) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	return vhs.history.query(from, to, step, time.Now())
+}
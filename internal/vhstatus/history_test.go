@@ -0,0 +1,133 @@
+package vhstatus
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func Test_History(t *testing.T) {
+	base := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	h := newHistory()
+	h.record(base, 0, true)
+	h.record(base.Add(time.Second*30), 2, true)               // 12:00:30 two players
+	h.record(base.Add(time.Minute*2), 1, true)                // 12:02:00 one left
+	h.record(base.Add(time.Minute*3), 0, false)               // 12:03:00 server down
+	h.record(base.Add(time.Minute*2+time.Second*10), 5, true) // out of order, counted at 12:03
+	h.record(base.Add(time.Hour*2), 0, false)
+
+	now := base.Add(time.Hour * 3)
+
+	// per minute
+	points, err := h.query(base, base.Add(time.Minute*4), time.Minute, now)
+	if err != nil {
+		t.Fatalf("History#query returned %v", err)
+	}
+	want := []HistoryPoint{
+		{Time: base, MaxPlayers: 2, AvgPlayers: 1, OnlineRatio: 1},
+		{Time: base.Add(time.Minute), MaxPlayers: 2, AvgPlayers: 2, OnlineRatio: 1},
+		{Time: base.Add(time.Minute * 2), MaxPlayers: 1, AvgPlayers: 1, OnlineRatio: 1},
+		{Time: base.Add(time.Minute * 3), MaxPlayers: 5, AvgPlayers: 5, OnlineRatio: 1},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("History#query(1m) returned %d points, want %d: %+v", len(points), len(want), points)
+	}
+	for i := range want {
+		if !points[i].Time.Equal(want[i].Time) || points[i].MaxPlayers != want[i].MaxPlayers ||
+			math.Abs(points[i].AvgPlayers-want[i].AvgPlayers) > 1e-9 ||
+			math.Abs(points[i].OnlineRatio-want[i].OnlineRatio) > 1e-9 {
+			t.Errorf("History#query(1m)[%d] = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+
+	// per hour, counts the current state until now.
+	points, err = h.query(base, base.Add(time.Hour*3), time.Hour, now)
+	if err != nil {
+		t.Fatalf("History#query returned %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("History#query(1h) returned %d points, want 3: %+v", len(points), points)
+	}
+	if points[0].MaxPlayers != 5 || points[1].OnlineRatio != 1 || points[1].AvgPlayers != 5 || points[2].OnlineRatio != 0 {
+		t.Errorf("History#query(1h) = %+v", points)
+	}
+
+	// 2 hours from the hours.
+	points, _ = h.query(base, base.Add(time.Hour*4), time.Hour*2, now)
+	if len(points) != 2 || !points[1].Time.Equal(base.Add(time.Hour*2)) {
+		t.Errorf("History#query(2h) = %+v", points)
+	}
+
+	// per day
+	points, _ = h.query(base.Add(-time.Hour*24), now, time.Hour*24, now)
+	if len(points) != 1 || points[0].MaxPlayers != 5 {
+		t.Errorf("History#query(24h) = %+v", points)
+	}
+}
+
+func Test_History_Query_Error(t *testing.T) {
+	h := newHistory()
+	now := time.Now()
+
+	cases := []struct {
+		from, to time.Time
+		step     time.Duration
+	}{
+		{now, now, time.Minute},
+		{now, now.Add(-time.Hour), time.Minute},
+		{now.Add(-time.Hour), now, time.Second * 30},
+		{now.Add(-time.Hour), now, time.Second * 90},
+		{now.Add(-time.Hour * 24 * 30), now, time.Minute},
+	}
+	for i, c := range cases {
+		if _, err := h.query(c.from, c.to, c.step, now); err == nil {
+			t.Errorf("History#query(case[%d]) did not return an error", i)
+		}
+	}
+}
+
+func Test_History_Retention(t *testing.T) {
+	base := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	h := newHistory()
+	h.record(base, 1, true)
+	h.record(base.Add(time.Hour*24*3), 1, true)
+
+	if n := len(h.Series[0].Buckets); n > 60*24*2+1 {
+		t.Errorf("the minute series has %d buckets, want <= %d", n, 60*24*2+1)
+	}
+	if n := len(h.Series[2].Buckets); n != 4 {
+		t.Errorf("the day series has %d buckets, want 4", n)
+	}
+
+	// 15m is made from the minutes, which have been removed for the first
+	// day, while 1h is made from the hours.
+	now := base.Add(time.Hour * 24 * 3)
+	if points, _ := h.query(base, base.Add(time.Hour), time.Minute*15, now); len(points) != 0 {
+		t.Errorf("History#query(15m, 3 days ago) = %+v, want no points", points)
+	}
+	if points, _ := h.query(base, base.Add(time.Hour), time.Hour, now); len(points) != 1 || points[0].MaxPlayers != 1 {
+		t.Errorf("History#query(1h, 3 days ago) = %+v, want 1 point", points)
+	}
+}
+
+func Test_RecordHistory_Snapshot(t *testing.T) {
+	at := time.Now().Add(-time.Hour)
+
+	vhs := new_vhs_instance()
+	vhs.SetStatus("Online")
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: at})
+	vhs.RecordHistory(at)
+
+	restored := New()
+	restored.Restore(vhs.Snapshot())
+
+	points, err := restored.History(at.Add(-time.Hour), time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("VHStatus#History returned %v", err)
+	}
+	if len(points) == 0 || points[0].MaxPlayers != 1 || points[len(points)-1].OnlineRatio != 1 {
+		t.Errorf("VHStatus#History of the restored status = %+v", points)
+	}
+}
@@ -0,0 +1,110 @@
+package vhstatus
+
+import (
+	"sort"
+	"time"
+)
+
+// Mod is a mod (plugin) loaded by the mod framework of the server.
+type Mod struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// ModError is a mod which has failed to load.
+type ModError struct {
+	Name    string    `json:"name"`
+	Version string    `json:"version"`
+	Error   string    `json:"error"`
+	At      time.Time `json:"at"`
+}
+
+// ModSet is the mods of the server, which the players may need as well.
+type ModSet struct {
+	// Framework is the mod framework and its version, e.g. "BepInEx 5.4.21.0".
+	// zero-string if the server runs without mods.
+	Framework string     `json:"framework"`
+	Mods      []Mod      `json:"mods"`   // sorted by name.
+	Errors    []ModError `json:"errors"` // in the order of the log.
+}
+
+// SetModFramework starts a new set of the mods, as the mod framework loads
+// all mods when the server starts.
+func (vhs *VHStatus) SetModFramework(framework string) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.mods = ModSet{Framework: framework}
+	vhs.modsStarted = false
+	vhs.updatedAt = time.Now()
+}
+
+// AddMod adds the loaded mod. The mod of the same name is replaced.
+func (vhs *VHStatus) AddMod(mod Mod) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	// copy on write, the slice may be shared with the fetched Params.
+	mods := make([]Mod, 0, len(vhs.mods.Mods)+1)
+	for _, m := range vhs.mods.Mods {
+		if m.Name != mod.Name {
+			mods = append(mods, m)
+		}
+	}
+	mods = append(mods, mod)
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Name < mods[j].Name })
+	vhs.mods.Mods = mods
+
+	vhs.updatedAt = time.Now()
+}
+
+// AddModError adds the mod which has failed to load.
+// The mod is removed from the loaded mods, if it has been logged as loading.
+func (vhs *VHStatus) AddModError(modErr ModError) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	mods := make([]Mod, 0, len(vhs.mods.Mods))
+	for _, m := range vhs.mods.Mods {
+		if m.Name != modErr.Name || m.Version != modErr.Version {
+			mods = append(mods, m)
+		}
+	}
+	vhs.mods.Mods = mods
+
+	errs := make([]ModError, len(vhs.mods.Errors), len(vhs.mods.Errors)+1)
+	copy(errs, vhs.mods.Errors)
+	vhs.mods.Errors = append(errs, modErr)
+
+	vhs.updatedAt = time.Now()
+}
+
+// ServerStarted is called when the Valheim server has started. The mods are
+// cleared if the server has restarted without loading them again.
+//
+// BepInEx loads the mods before the server logs its start, so the mods are
+// kept through the first start after they have been loaded.
+func (vhs *VHStatus) ServerStarted() {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	if !vhs.modsStarted {
+		vhs.modsStarted = true
+		return
+	}
+	if vhs.mods.Framework != "" || len(vhs.mods.Mods) > 0 || len(vhs.mods.Errors) > 0 {
+		vhs.mods = ModSet{}
+		vhs.updatedAt = time.Now()
+	}
+}
+
+// modSet returns the mods of the server.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) modSet() ModSet {
+	return ModSet{
+		Framework: vhs.mods.Framework,
+		Mods:      append([]Mod{}, vhs.mods.Mods...),
+		Errors:    append([]ModError{}, vhs.mods.Errors...),
+	}
+}
@@ -0,0 +1,56 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Mods(t *testing.T) {
+	now := time.Now()
+
+	vhs := New()
+	if got := vhs.Params().Mods; got.Framework != "" || got.Mods == nil || len(got.Mods) != 0 || got.Errors == nil {
+		t.Fatalf("New().Params().Mods = %#v, want empty", got)
+	}
+
+	vhs.SetModFramework("BepInEx 5.4.21.0")
+	vhs.AddMod(Mod{Name: "ValheimPlus", Version: "0.9.9.11", LoadedAt: now})
+	vhs.AddMod(Mod{Name: "Jotunn", Version: "2.10.0", LoadedAt: now})
+	vhs.AddMod(Mod{Name: "PlanBuild", Version: "0.14.0", LoadedAt: now})
+	vhs.AddModError(ModError{Name: "PlanBuild", Version: "0.14.0", Error: "Object reference not set", At: now})
+	vhs.AddModError(ModError{Name: "Epic Loot", Version: "0.9.35", Error: "it has missing dependencies", At: now})
+	fetched := vhs.Params().Mods
+	vhs.AddMod(Mod{Name: "Jotunn", Version: "2.11.0", LoadedAt: now})
+
+	got := vhs.Params().Mods
+	if got.Framework != "BepInEx 5.4.21.0" {
+		t.Errorf("Params().Mods.Framework = %q", got.Framework)
+	}
+	if len(got.Mods) != 2 || got.Mods[0].Name != "Jotunn" || got.Mods[0].Version != "2.11.0" || got.Mods[1].Name != "ValheimPlus" {
+		t.Errorf("Params().Mods.Mods = %+v, want Jotunn 2.11.0 and ValheimPlus", got.Mods)
+	}
+	if len(got.Errors) != 2 || got.Errors[0].Name != "PlanBuild" || got.Errors[1].Name != "Epic Loot" {
+		t.Errorf("Params().Mods.Errors = %+v, want in the order of the log", got.Errors)
+	}
+	// the fetched params are not changed.
+	if fetched.Mods[0].Version != "2.10.0" {
+		t.Errorf("fetched Mods has been changed: %+v", fetched.Mods)
+	}
+
+	// persisted with the snapshot.
+	restored := New()
+	restored.Restore(vhs.Snapshot())
+	if got := restored.Params().Mods; got.Framework != "BepInEx 5.4.21.0" || len(got.Mods) != 2 || len(got.Errors) != 2 {
+		t.Errorf("restored Params().Mods = %+v", got)
+	}
+
+	// the server starts with the mods, and then restarts without them.
+	vhs.ServerStarted()
+	if got := vhs.Params().Mods; len(got.Mods) != 2 {
+		t.Errorf("Params().Mods after the first start = %+v, want the mods", got)
+	}
+	vhs.ServerStarted()
+	if got := vhs.Params().Mods; got.Framework != "" || len(got.Mods) != 0 || len(got.Errors) != 0 {
+		t.Errorf("Params().Mods after the restart without the mods = %+v, want empty", got)
+	}
+}
@@ -0,0 +1,149 @@
+package vhstatus
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// notificationBufferSize is the buffer size of the subscriber channel.
+// Notifications to a subscriber whose buffer is full are dropped.
+const notificationBufferSize = 64
+
+// Notification is sent to the subscribers when VHStatus has been changed.
+type Notification struct {
+	// Event is the source of the change (e.g. vhlogwatcher.VHLogEvent).
+	Event interface{}
+
+	// Diff is the fields of Params changed since the last notification,
+	// keyed by the JSON name. "players" has only the changed players.
+	Diff map[string]interface{}
+
+	// Params is the Params after the change.
+	Params Params
+}
+
+// Subscribe registers a subscriber, and returns the channel to receive
+// notifications and the function to unsubscribe.
+// The channel is closed when unsubscribed.
+func (vhs *VHStatus) Subscribe() (<-chan Notification, func()) {
+	vhs.subMu.Lock()
+	defer vhs.subMu.Unlock()
+
+	if len(vhs.subscribers) == 0 {
+		vhs.lastPublished = paramsToMap(vhs.Params())
+	}
+
+	ch := make(chan Notification, notificationBufferSize)
+	vhs.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		vhs.subMu.Lock()
+		defer vhs.subMu.Unlock()
+
+		if _, ok := vhs.subscribers[ch]; ok {
+			delete(vhs.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// Publish notifies the subscribers of the changes caused by the event.
+func (vhs *VHStatus) Publish(event interface{}) {
+	vhs.subMu.Lock()
+	defer vhs.subMu.Unlock()
+
+	if len(vhs.subscribers) == 0 {
+		return
+	}
+
+	params := vhs.Params()
+	current := paramsToMap(params)
+	n := Notification{
+		Event:  event,
+		Diff:   diffParamsMap(vhs.lastPublished, current),
+		Params: params,
+	}
+	vhs.lastPublished = current
+
+	for ch, _ := range vhs.subscribers {
+		select {
+		case ch <- n:
+		default: // the subscriber is too slow.
+		}
+	}
+}
+
+func paramsToMap(params Params) map[string]interface{} {
+	var m map[string]interface{}
+	data, _ := json.Marshal(params)
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// playerVolatileKeys are the keys of a player which change as time goes by.
+// They are ignored when detecting the changed players.
+var playerVolatileKeys = []string{"current_session_seconds", "total_playtime_seconds"}
+
+func diffParamsMap(prev, current map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for k, v := range current {
+		if k == "players" {
+			continue
+		}
+		if !reflect.DeepEqual(prev[k], v) {
+			diff[k] = v
+		}
+	}
+
+	prevPlayers := make(map[interface{}]map[string]interface{})
+	if players, ok := prev["players"].([]interface{}); ok {
+		for _, p := range players {
+			if p, ok := p.(map[string]interface{}); ok {
+				prevPlayers[p["steam_id"]] = p
+			}
+		}
+	}
+
+	changed := make([]interface{}, 0)
+	if players, ok := current["players"].([]interface{}); ok {
+		for _, p := range players {
+			p, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if !equalPlayerMap(prevPlayers[p["steam_id"]], p) {
+				changed = append(changed, p)
+			}
+		}
+	}
+	if len(changed) > 0 {
+		diff["players"] = changed
+	}
+
+	return diff
+}
+
+func equalPlayerMap(lhs, rhs map[string]interface{}) bool {
+	if lhs == nil || rhs == nil {
+		return lhs == nil && rhs == nil
+	}
+	for k, v := range rhs {
+		if isPlayerVolatileKey(k) {
+			continue
+		}
+		if !reflect.DeepEqual(lhs[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPlayerVolatileKey(key string) bool {
+	for _, k := range playerVolatileKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Subscribe(t *testing.T) {
+	vhs := New()
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: time.Now()})
+
+	ch, unsubscribe := vhs.Subscribe()
+
+	vhs.SetDay("2")
+	vhs.UpdatePlayer(Player{SteamID: "2", Status: "Connection", UpdatedAt: time.Now()})
+	vhs.Publish("event")
+
+	select {
+	case n := <-ch:
+		if n.Event != "event" {
+			t.Errorf("Notification.Event = %v, want %q", n.Event, "event")
+		}
+		if n.Diff["day"] != "2" {
+			t.Errorf("Notification.Diff[day] = %v, want %q", n.Diff["day"], "2")
+		}
+		if _, ok := n.Diff["status"]; ok {
+			t.Errorf("Notification.Diff has unchanged field: %v", n.Diff)
+		}
+		players, _ := n.Diff["players"].([]interface{})
+		if len(players) != 1 || players[0].(map[string]interface{})["steam_id"] != "2" {
+			t.Errorf("Notification.Diff[players] = %v, want only the player 2", n.Diff["players"])
+		}
+		if n.Params.ActivePlayerCount != 2 {
+			t.Errorf("Notification.Params.ActivePlayerCount = %d, want 2", n.Params.ActivePlayerCount)
+		}
+	default:
+		t.Fatal("VHStatus#Publish did not notify the subscriber")
+	}
+
+	// no change
+	vhs.Publish("event")
+	if n := <-ch; n.Diff["day"] != nil || n.Diff["players"] != nil {
+		t.Errorf("Notification.Diff has unchanged field: %v", n.Diff)
+	}
+
+	unsubscribe()
+	unsubscribe() // can be called twice
+	if _, ok := <-ch; ok {
+		t.Error("the channel is not closed after unsubscribed")
+	}
+	vhs.Publish("event") // must not panic
+}
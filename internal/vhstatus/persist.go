@@ -0,0 +1,244 @@
+package vhstatus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const snapshotVersion = 1
+
+// ErrNoSnapshot is returned by Persister#Load when no snapshot has been saved.
+var ErrNoSnapshot = errors.New("no snapshot")
+
+// Checkpoint is the position of the last log line applied to VHStatus.
+type Checkpoint struct {
+	File   string `json:"file"`   // name of the log file.
+	Head   string `json:"head"`   // first console log line of the log file.
+	Offset int64  `json:"offset"` // byte offset just after the last consumed line.
+}
+
+// Snapshot is the persistent state of VHStatus.
+type Snapshot struct {
+	Version    int        `json:"version"`
+	SavedAt    time.Time  `json:"saved_at"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+
+	Status         string               `json:"status"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	ServerID       string               `json:"server_id"`
+	ValheimVersion string               `json:"valheim_version"`
+	WorldName      string               `json:"world_name"`
+	WorldSeed      string               `json:"world_seed"`
+	Day            string               `json:"day"`
+	Players        []Player             `json:"players"`
+	Sessions       map[string][]Session `json:"sessions"`
+	GlobalKeys     []GlobalKey          `json:"global_keys,omitempty"`
+	WorldSave      *WorldSaveStats      `json:"world_save,omitempty"`
+	WorldSizes     []WorldSize          `json:"world_sizes,omitempty"`
+	Raids          []Raid               `json:"raids,omitempty"`
+	History        *History             `json:"history,omitempty"`
+
+	ConnectionFailures map[string][]ConnectionFailure `json:"connection_failures,omitempty"`
+	CustomEvents       []CustomEventStats             `json:"custom_events,omitempty"`
+	Mods               *ModSet                        `json:"mods,omitempty"`
+	ModsStarted        bool                           `json:"mods_started,omitempty"`
+}
+
+// Persister saves and loads the snapshot of VHStatus.
+type Persister interface {
+	Save(snapshot Snapshot) error
+
+	// Load returns ErrNoSnapshot if no snapshot has been saved.
+	Load() (Snapshot, error)
+}
+
+// JSONFilePersister saves the snapshot into a JSON file.
+type JSONFilePersister struct {
+	path string
+}
+
+func NewJSONFilePersister(path string) *JSONFilePersister {
+	return &JSONFilePersister{path: path}
+}
+
+// Save writes the snapshot into a temporary file and renames it,
+// so the previous snapshot is kept if the writing fails.
+func (p *JSONFilePersister) Save(snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(p.path), filepath.Base(p.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.path)
+}
+
+func (p *JSONFilePersister) Load() (Snapshot, error) {
+	var snapshot Snapshot
+
+	data, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return snapshot, ErrNoSnapshot
+	} else if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s: %w", p.path, err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return snapshot, fmt.Errorf("%s: unsupported snapshot version %d", p.path, snapshot.Version)
+	}
+	return snapshot, nil
+}
+
+//-----------------------------------------------------------------------------
+// VHStatus
+//-----------------------------------------------------------------------------
+
+func (vhs *VHStatus) Checkpoint() Checkpoint {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	return vhs.checkpoint
+}
+
+func (vhs *VHStatus) SetCheckpoint(cp Checkpoint) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.checkpoint = cp
+}
+
+// Snapshot returns the current state of VHStatus.
+func (vhs *VHStatus) Snapshot() Snapshot {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	players := make([]Player, len(vhs.players))
+	copy(players, vhs.players)
+
+	sessions := make(map[string][]Session, len(vhs.sessions))
+	for id, s := range vhs.sessions {
+		sessions[id] = make([]Session, len(s))
+		copy(sessions[id], s)
+	}
+
+	failures := make(map[string][]ConnectionFailure, len(vhs.connectionFailures))
+	for id, f := range vhs.connectionFailures {
+		failures[id] = append([]ConnectionFailure(nil), f...)
+	}
+
+	history := vhs.history.copy()
+	worldSave := vhs.worldSave
+	mods := vhs.modSet()
+
+	return Snapshot{
+		Version:        snapshotVersion,
+		SavedAt:        time.Now(),
+		Checkpoint:     vhs.checkpoint,
+		Status:         vhs.status,
+		UpdatedAt:      vhs.updatedAt,
+		ServerID:       vhs.serverID,
+		ValheimVersion: vhs.valheimVersion,
+		WorldName:      vhs.worldName,
+		WorldSeed:      vhs.worldSeed,
+		Day:            vhs.day,
+		Players:        players,
+		Sessions:       sessions,
+		GlobalKeys:     append([]GlobalKey(nil), vhs.globalKeys...),
+		WorldSave:      &worldSave,
+		WorldSizes:     append([]WorldSize(nil), vhs.worldSizes...),
+		Raids:          append([]Raid(nil), vhs.raids...),
+		History:        &history,
+
+		ConnectionFailures: failures,
+		CustomEvents:       vhs.customEventStats(),
+		Mods:               &mods,
+		ModsStarted:        vhs.modsStarted,
+	}
+}
+
+// Restore replaces the state of VHStatus with the snapshot.
+func (vhs *VHStatus) Restore(snapshot Snapshot) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.checkpoint = snapshot.Checkpoint
+	vhs.status = snapshot.Status
+	vhs.updatedAt = snapshot.UpdatedAt
+	vhs.serverID = snapshot.ServerID
+	vhs.valheimVersion = snapshot.ValheimVersion
+	vhs.worldName = snapshot.WorldName
+	vhs.worldSeed = snapshot.WorldSeed
+	vhs.day = snapshot.Day
+	vhs.globalKeys = append([]GlobalKey(nil), snapshot.GlobalKeys...)
+	vhs.worldSizes = append([]WorldSize(nil), snapshot.WorldSizes...)
+	vhs.raids = append([]Raid(nil), snapshot.Raids...)
+	vhs.connectionFailures = make(map[string][]ConnectionFailure, len(snapshot.ConnectionFailures))
+	for id, f := range snapshot.ConnectionFailures {
+		if len(f) > 0 {
+			vhs.connectionFailures[id] = append([]ConnectionFailure(nil), f...)
+		}
+	}
+	vhs.evictConnectionFailures()
+	vhs.customEvents = make(map[string]CustomEventStats, len(snapshot.CustomEvents))
+	for _, s := range snapshot.CustomEvents {
+		vhs.customEvents[s.Name] = s
+	}
+	vhs.mods = ModSet{}
+	if snapshot.Mods != nil {
+		vhs.mods = *snapshot.Mods
+	}
+	vhs.modsStarted = snapshot.ModsStarted
+	// the threshold is configured, not restored.
+	threshold := vhs.worldSave.SlowThresholdMs
+	vhs.worldSave = WorldSaveStats{}
+	if snapshot.WorldSave != nil {
+		vhs.worldSave = *snapshot.WorldSave
+	}
+	vhs.worldSave.SlowThresholdMs = threshold
+
+	vhs.players = make([]Player, len(snapshot.Players))
+	copy(vhs.players, snapshot.Players)
+	for i, _ := range vhs.players {
+		// the snapshots saved before the name history was introduced.
+		if vhs.players[i].Name != "" {
+			vhs.players[i].addKnownName(vhs.players[i].Name)
+		}
+		// the snapshots saved before the crossplay only have Steam players.
+		if vhs.players[i].Platform == "" {
+			vhs.players[i].Platform = "Steam"
+		}
+	}
+
+	vhs.sessions = make(map[string][]Session, len(snapshot.Sessions))
+	for id, s := range snapshot.Sessions {
+		vhs.sessions[id] = make([]Session, len(s))
+		copy(vhs.sessions[id], s)
+	}
+
+	vhs.history = newHistory()
+	if snapshot.History != nil {
+		vhs.history = snapshot.History.copy()
+	}
+
+	vhs.countActivePlayers()
+}
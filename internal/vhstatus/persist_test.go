@@ -0,0 +1,84 @@
+package vhstatus
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_JSONFilePersister_NoSnapshot(t *testing.T) {
+	p := NewJSONFilePersister(filepath.Join(t.TempDir(), "vhstatus.json"))
+
+	if _, err := p.Load(); err != ErrNoSnapshot {
+		t.Errorf("JSONFilePersister#Load(not saved) returned %v, want ErrNoSnapshot", err)
+	}
+}
+
+func Test_JSONFilePersister_UnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vhstatus.json")
+	ioutil.WriteFile(path, []byte(`{"version": 999}`), 0644)
+
+	p := NewJSONFilePersister(path)
+	if _, err := p.Load(); err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+		t.Errorf("JSONFilePersister#Load(unsupported version) returned %v", err)
+	}
+}
+
+func Test_SnapshotRestore(t *testing.T) {
+	now := time.Now()
+	cp := Checkpoint{File: "vhserver-console.log", Head: "head", Offset: 1234}
+
+	src := New()
+	src.SetStatus("Online")
+	src.SetServerID("1234567890")
+	src.SetValheimVersion("0.148.6")
+	src.SetWorldName("test-world")
+	src.SetWorldSeed("testseed")
+	src.SetDay("12")
+	src.UpdatePlayer(Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: now.Add(-time.Hour)})
+	src.UpdatePlayer(Player{SteamID: "2", Status: "Connection", Name: "player2", UpdatedAt: now.Add(-time.Hour)})
+	src.UpdatePlayer(Player{SteamID: "2", Status: "Disconnection", UpdatedAt: now.Add(-time.Minute)})
+	src.AddPlayerDeath("player1", now)
+	src.SetCheckpoint(cp)
+
+	p := NewJSONFilePersister(filepath.Join(t.TempDir(), "vhstatus.json"))
+	if err := p.Save(src.Snapshot()); err != nil {
+		t.Fatalf("JSONFilePersister#Save returned %v", err)
+	}
+	snapshot, err := p.Load()
+	if err != nil {
+		t.Fatalf("JSONFilePersister#Load returned %v", err)
+	}
+
+	dst := New()
+	dst.Restore(snapshot)
+
+	if got := dst.Checkpoint(); got != cp {
+		t.Errorf("restored checkpoint = %+v, want %+v", got, cp)
+	}
+
+	want := src.Params()
+	got := dst.Params()
+	if got.Status != want.Status || got.ServerID != want.ServerID ||
+		got.ValheimVersion != want.ValheimVersion || got.WorldName != want.WorldName ||
+		got.WorldSeed != want.WorldSeed || got.Day != want.Day {
+		t.Errorf("restored params = %+v, want %+v", got, want)
+	}
+	if got.ActivePlayerCount != 1 {
+		t.Errorf("restored ActivePlayerCount = %d, want 1", got.ActivePlayerCount)
+	}
+	if got.TotalDeaths != 1 {
+		t.Errorf("restored TotalDeaths = %d, want 1", got.TotalDeaths)
+	}
+	if len(got.Players) != 2 {
+		t.Fatalf("restored %d players, want 2", len(got.Players))
+	}
+	for i, _ := range got.Players {
+		if got.Players[i].SessionCount != want.Players[i].SessionCount {
+			t.Errorf("restored Players[%d].SessionCount = %d, want %d",
+				i, got.Players[i].SessionCount, want.Players[i].SessionCount)
+		}
+	}
+}
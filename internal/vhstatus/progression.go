@@ -0,0 +1,77 @@
+package vhstatus
+
+import (
+	"time"
+)
+
+// GlobalKey is a global key of the world, which is set when the world has
+// progressed, e.g. "defeated_eikthyr".
+type GlobalKey struct {
+	Key   string    `json:"key"`
+	SetAt time.Time `json:"set_at"` // first time the key was logged.
+}
+
+// Boss is a boss of Valheim and the global key set when it is defeated.
+type Boss struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Bosses are the bosses in the order of the progression.
+var Bosses = []Boss{
+	{Key: "defeated_eikthyr", Name: "Eikthyr"},
+	{Key: "defeated_gdking", Name: "The Elder"},
+	{Key: "defeated_bonemass", Name: "Bonemass"},
+	{Key: "defeated_dragon", Name: "Moder"},
+	{Key: "defeated_goblinking", Name: "Yagluth"},
+	{Key: "defeated_queen", Name: "The Queen"},
+	{Key: "defeated_fader", Name: "Fader"},
+}
+
+// DefeatedBoss is a boss which has been defeated in the world.
+type DefeatedBoss struct {
+	Boss
+	DefeatedAt time.Time `json:"defeated_at"`
+}
+
+func (b DefeatedBoss) DefeatedAtAsString() string {
+	return b.DefeatedAt.Format(time.RFC3339)
+}
+
+// SetGlobalKey records the global key.
+// A key which has already been set keeps the first time.
+func (vhs *VHStatus) SetGlobalKey(key string, setAt time.Time) {
+	if key == "" {
+		return
+	}
+
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	for _, k := range vhs.globalKeys {
+		if k.Key == key {
+			return
+		}
+	}
+	// copy on write, the slice may be shared with the fetched Params.
+	keys := make([]GlobalKey, len(vhs.globalKeys), len(vhs.globalKeys)+1)
+	copy(keys, vhs.globalKeys)
+	vhs.globalKeys = append(keys, GlobalKey{Key: key, SetAt: setAt})
+
+	vhs.updatedAt = time.Now()
+}
+
+// defeatedBosses returns the defeated bosses in the order of the progression.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) defeatedBosses() []DefeatedBoss {
+	ret := make([]DefeatedBoss, 0, len(Bosses))
+	for _, boss := range Bosses {
+		for _, k := range vhs.globalKeys {
+			if k.Key == boss.Key {
+				ret = append(ret, DefeatedBoss{Boss: boss, DefeatedAt: k.SetAt})
+				break
+			}
+		}
+	}
+	return ret
+}
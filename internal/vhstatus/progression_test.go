@@ -0,0 +1,52 @@
+package vhstatus
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_SetGlobalKey(t *testing.T) {
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	vhs := New()
+	if p := vhs.Params(); len(p.GlobalKeys) != 0 || len(p.DefeatedBosses) != 0 {
+		t.Fatalf("New().Params() has progression %+v %+v", p.GlobalKeys, p.DefeatedBosses)
+	}
+
+	vhs.SetGlobalKey("defeated_bonemass", t0.Add(time.Hour*2))
+	vhs.SetGlobalKey("killed_surtling", t0.Add(time.Hour))
+	vhs.SetGlobalKey("defeated_eikthyr", t0)
+	vhs.SetGlobalKey("defeated_eikthyr", t0.Add(time.Hour*3)) // already set.
+	vhs.SetGlobalKey("", t0)
+
+	p := vhs.Params()
+	wantKeys := []GlobalKey{
+		{Key: "defeated_bonemass", SetAt: t0.Add(time.Hour * 2)},
+		{Key: "killed_surtling", SetAt: t0.Add(time.Hour)},
+		{Key: "defeated_eikthyr", SetAt: t0},
+	}
+	if !reflect.DeepEqual(p.GlobalKeys, wantKeys) {
+		t.Errorf("Params().GlobalKeys = %+v, want %+v", p.GlobalKeys, wantKeys)
+	}
+	// in the order of the progression, not the order they were set.
+	wantBosses := []DefeatedBoss{
+		{Boss: Boss{Key: "defeated_eikthyr", Name: "Eikthyr"}, DefeatedAt: t0},
+		{Boss: Boss{Key: "defeated_bonemass", Name: "Bonemass"}, DefeatedAt: t0.Add(time.Hour * 2)},
+	}
+	if !reflect.DeepEqual(p.DefeatedBosses, wantBosses) {
+		t.Errorf("Params().DefeatedBosses = %+v, want %+v", p.DefeatedBosses, wantBosses)
+	}
+
+	// the fetched params are not changed by the later keys.
+	vhs.SetGlobalKey("defeated_gdking", t0.Add(time.Hour*4))
+	if len(p.GlobalKeys) != 3 {
+		t.Errorf("fetched GlobalKeys has been changed: %+v", p.GlobalKeys)
+	}
+
+	dst := New()
+	dst.Restore(vhs.Snapshot())
+	if got := dst.Params().DefeatedBosses; len(got) != 3 || got[1].Key != "defeated_gdking" {
+		t.Errorf("restored DefeatedBosses = %+v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package vhstatus
+
+import (
+	"time"
+)
+
+// Raid is a random event of the world, e.g. "army_eikthyr".
+type Raid struct {
+	Name      string    `json:"name"`
+	Title     string    `json:"title"` // message shown in the game.
+	StartedAt time.Time `json:"started_at"`
+
+	// EndsAt is estimated from the duration of the event, because the end of
+	// the event is not logged. It is earlier if the server has gone down.
+	EndsAt time.Time `json:"ends_at"`
+}
+
+func (r Raid) StartedAtAsString() string {
+	return r.StartedAt.Format(time.RFC3339)
+}
+
+func (r Raid) EndsAtAsString() string {
+	return r.EndsAt.Format(time.RFC3339)
+}
+
+// RandomEvent is a kind of the random events.
+type RandomEvent struct {
+	Title    string
+	Duration time.Duration
+}
+
+// RandomEvents are the known random events, keyed by the name in the log.
+var RandomEvents = map[string]RandomEvent{
+	"army_eikthyr":  {Title: "Eikthyr rallies the creatures of the forest", Duration: time.Second * 90},
+	"army_theelder": {Title: "The forest is moving...", Duration: time.Second * 120},
+	"army_bonemass": {Title: "A foul smell from the swamp", Duration: time.Second * 150},
+	"army_moder":    {Title: "A cold wind blows from the mountains", Duration: time.Second * 150},
+	"army_goblin":   {Title: "The horde is attacking", Duration: time.Second * 120},
+	"army_gjall":    {Title: "What's that sound?", Duration: time.Second * 120},
+	"army_seekers":  {Title: "They sought you out", Duration: time.Second * 120},
+	"foresttrolls":  {Title: "The ground is shaking", Duration: time.Second * 80},
+	"skeletons":     {Title: "Skeleton surprise", Duration: time.Second * 120},
+	"blobs":         {Title: "A foul smell from the swamp", Duration: time.Second * 120},
+	"wolves":        {Title: "You are being hunted", Duration: time.Second * 120},
+	"bats":          {Title: "You stirred the cauldron", Duration: time.Second * 120},
+	"surtlings":     {Title: "There's a smell of sulfur in the air", Duration: time.Second * 120},
+}
+
+// DefaultRaidDuration is the duration of the unknown random events.
+const DefaultRaidDuration = time.Second * 120
+
+// maxRaids is the number of the recent raids kept.
+const maxRaids = 20
+
+// StartRaid records the random event which has started at the time.
+// A running raid is ended by the new one, as only one random event runs at
+// once in the world.
+func (vhs *VHStatus) StartRaid(name string, startedAt time.Time) Raid {
+	raid := Raid{Name: name, Title: name, StartedAt: startedAt, EndsAt: startedAt.Add(DefaultRaidDuration)}
+	if ev, ok := RandomEvents[name]; ok {
+		raid.Title = ev.Title
+		raid.EndsAt = startedAt.Add(ev.Duration)
+	}
+
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.endRaid(startedAt)
+	// copy on write, the slice may be shared with the fetched Params.
+	n := len(vhs.raids)
+	if n >= maxRaids {
+		n = maxRaids - 1
+	}
+	raids := make([]Raid, n, n+1)
+	copy(raids, vhs.raids[len(vhs.raids)-n:])
+	vhs.raids = append(raids, raid)
+
+	vhs.updatedAt = time.Now()
+	return raid
+}
+
+// EndRaid ends the running raid at the time, e.g. when the server has gone down.
+func (vhs *VHStatus) EndRaid(endedAt time.Time) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	if vhs.endRaid(endedAt) {
+		vhs.updatedAt = time.Now()
+	}
+}
+
+// endRaid returns true if a running raid has been ended.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) endRaid(endedAt time.Time) bool {
+	n := len(vhs.raids)
+	if n == 0 || endedAt.IsZero() || !vhs.raids[n-1].EndsAt.After(endedAt) {
+		return false
+	}
+
+	raids := append([]Raid(nil), vhs.raids...)
+	raids[n-1].EndsAt = endedAt
+	vhs.raids = raids
+	return true
+}
+
+// currentRaid returns the raid running at the time, or nil.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) currentRaid(now time.Time) *Raid {
+	n := len(vhs.raids)
+	if n == 0 || !vhs.raids[n-1].EndsAt.After(now) {
+		return nil
+	}
+	raid := vhs.raids[n-1]
+	return &raid
+}
+
+// recentRaids returns the raids, newest first.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) recentRaids() []Raid {
+	ret := make([]Raid, 0, len(vhs.raids))
+	for i := len(vhs.raids) - 1; i >= 0; i-- {
+		ret = append(ret, vhs.raids[i])
+	}
+	return ret
+}
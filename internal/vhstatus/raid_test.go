@@ -0,0 +1,66 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_StartRaid(t *testing.T) {
+	now := time.Now()
+
+	vhs := New()
+	if p := vhs.Params(); p.CurrentRaid != nil || len(p.RecentRaids) != 0 {
+		t.Fatalf("New().Params() has raids %+v %+v", p.CurrentRaid, p.RecentRaids)
+	}
+
+	// known event
+	raid := vhs.StartRaid("army_eikthyr", now.Add(-time.Hour))
+	if raid.Title != RandomEvents["army_eikthyr"].Title || !raid.EndsAt.Equal(now.Add(-time.Hour).Add(RandomEvents["army_eikthyr"].Duration)) {
+		t.Errorf("StartRaid(army_eikthyr) = %+v", raid)
+	}
+	if p := vhs.Params(); p.CurrentRaid != nil || len(p.RecentRaids) != 1 {
+		t.Errorf("Params() after the raid has ended = %+v %+v", p.CurrentRaid, p.RecentRaids)
+	}
+
+	// unknown event, which is running now.
+	vhs.StartRaid("army_unknown", now.Add(-time.Second*10))
+	p := vhs.Params()
+	if p.CurrentRaid == nil || p.CurrentRaid.Name != "army_unknown" || p.CurrentRaid.Title != "army_unknown" ||
+		!p.CurrentRaid.EndsAt.Equal(now.Add(-time.Second*10).Add(DefaultRaidDuration)) {
+		t.Errorf("Params().CurrentRaid = %+v", p.CurrentRaid)
+	}
+	if len(p.RecentRaids) != 2 || p.RecentRaids[0].Name != "army_unknown" || p.RecentRaids[1].Name != "army_eikthyr" {
+		t.Errorf("Params().RecentRaids = %+v, want newest first", p.RecentRaids)
+	}
+
+	// the server has gone down.
+	vhs.EndRaid(now.Add(-time.Second))
+	if got := vhs.Params(); got.CurrentRaid != nil || !got.RecentRaids[0].EndsAt.Equal(now.Add(-time.Second)) {
+		t.Errorf("Params() after EndRaid = %+v %+v", got.CurrentRaid, got.RecentRaids)
+	}
+	// the fetched params are not changed.
+	if !p.RecentRaids[0].EndsAt.After(now) {
+		t.Errorf("fetched RecentRaids has been changed: %+v", p.RecentRaids)
+	}
+
+	// a new raid ends the running one.
+	vhs.StartRaid("wolves", now)
+	vhs.StartRaid("bats", now.Add(time.Second*30))
+	if got := vhs.Params().RecentRaids; !got[1].EndsAt.Equal(now.Add(time.Second * 30)) {
+		t.Errorf("the previous raid ends at %v, want %v", got[1].EndsAt, now.Add(time.Second*30))
+	}
+
+	// the recent raids are limited.
+	for i := 0; i < maxRaids; i++ {
+		vhs.StartRaid("skeletons", now.Add(time.Minute*time.Duration(i)))
+	}
+	if got := vhs.Params().RecentRaids; len(got) != maxRaids || got[len(got)-1].Name != "skeletons" {
+		t.Errorf("Params().RecentRaids has %d raids, the oldest is %+v", len(got), got[len(got)-1])
+	}
+
+	dst := New()
+	dst.Restore(vhs.Snapshot())
+	if got := dst.Params(); got.CurrentRaid == nil || len(got.RecentRaids) != maxRaids {
+		t.Errorf("restored raids = %+v %d", got.CurrentRaid, len(got.RecentRaids))
+	}
+}
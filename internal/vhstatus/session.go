@@ -0,0 +1,102 @@
+package vhstatus
+
+import (
+	"time"
+)
+
+// Session is a period in which a player has been connected to the server.
+type Session struct {
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"` // zero-value while the session continues.
+	Seconds   int64     `json:"seconds"`  // length of the session, it is calculated when fetched.
+}
+
+func (s Session) IsActive() bool {
+	return s.EndedAt.IsZero()
+}
+
+// Length returns the length of the session.
+// If the session is still active, it is measured up to now.
+func (s Session) Length(now time.Time) time.Duration {
+	end := s.EndedAt
+	if s.IsActive() {
+		end = now
+	}
+	if end.Before(s.StartedAt) {
+		return 0
+	}
+	return end.Sub(s.StartedAt)
+}
+
+// startSession starts a new session of the player if there is no active one.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) startSession(steamID string, startedAt time.Time) {
+	sessions := vhs.sessions[steamID]
+	if len(sessions) > 0 && sessions[len(sessions)-1].IsActive() {
+		return
+	}
+	if startedAt.IsZero() {
+		startedAt = time.Now()
+	}
+	vhs.sessions[steamID] = append(sessions, Session{StartedAt: startedAt})
+}
+
+// endSession ends the active session of the player.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) endSession(steamID string, endedAt time.Time) {
+	sessions := vhs.sessions[steamID]
+	if len(sessions) == 0 || !sessions[len(sessions)-1].IsActive() {
+		return
+	}
+	if endedAt.IsZero() {
+		endedAt = time.Now()
+	}
+	sessions[len(sessions)-1].EndedAt = endedAt
+}
+
+// fillSessionParams sets the session information to the player.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) fillSessionParams(p *Player, now time.Time) {
+	sessions := vhs.sessions[p.SteamID]
+
+	var total time.Duration
+	for _, s := range sessions {
+		total += s.Length(now)
+	}
+
+	p.SessionCount = len(sessions)
+	p.TotalPlaytimeSeconds = int64(total / time.Second)
+	p.CurrentSessionStartedAt = time.Time{}
+	p.CurrentSessionSeconds = 0
+	if len(sessions) > 0 && sessions[len(sessions)-1].IsActive() {
+		current := sessions[len(sessions)-1]
+		p.CurrentSessionStartedAt = current.StartedAt
+		p.CurrentSessionSeconds = int64(current.Length(now) / time.Second)
+	}
+}
+
+// PlayerSessions returns the sessions of the player, oldest first.
+// If the player is not registered, the second return value is false.
+func (vhs *VHStatus) PlayerSessions(steamID string) ([]Session, bool) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	found := false
+	for i, _ := range vhs.players {
+		if vhs.players[i].SteamID == steamID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	now := time.Now()
+	sessions := make([]Session, len(vhs.sessions[steamID]))
+	copy(sessions, vhs.sessions[steamID])
+	for i, _ := range sessions {
+		sessions[i].Seconds = int64(sessions[i].Length(now) / time.Second)
+	}
+	return sessions, true
+}
@@ -0,0 +1,130 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Session_Length(t *testing.T) {
+	now := time.Now()
+
+	cases := []struct {
+		session Session
+		want    time.Duration
+	}{
+		{Session{StartedAt: now.Add(-time.Hour), EndedAt: now.Add(-time.Minute)}, time.Minute * 59},
+		{Session{StartedAt: now.Add(-time.Hour)}, time.Hour},
+		{Session{StartedAt: now, EndedAt: now.Add(-time.Minute)}, 0},
+	}
+
+	for i, c := range cases {
+		if got := c.session.Length(now); got != c.want {
+			t.Errorf("Session#Length(case[%d]) = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func Test_UpdatePlayer_Sessions(t *testing.T) {
+	base := time.Now().Add(-time.Hour * 3)
+
+	vhs := new_vhs_instance()
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: base})
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Got Character", Name: "player1", UpdatedAt: base.Add(time.Minute)})
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Disconnection", UpdatedAt: base.Add(time.Hour)})
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Disconnection", UpdatedAt: base.Add(time.Hour * 2)})
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: base.Add(time.Hour * 2)})
+
+	sessions, ok := vhs.PlayerSessions("1")
+	if !ok {
+		t.Fatal("VHStatus#PlayerSessions(registered player) returned false")
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("VHStatus#PlayerSessions returned %d sessions, want 2", len(sessions))
+	}
+	if !sessions[0].StartedAt.Equal(base) || !sessions[0].EndedAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("VHStatus#PlayerSessions()[0] = %+v, want started at %v and ended at %v",
+			sessions[0], base, base.Add(time.Hour))
+	}
+	if sessions[0].Seconds != 3600 {
+		t.Errorf("VHStatus#PlayerSessions()[0].Seconds = %d, want 3600", sessions[0].Seconds)
+	}
+	if !sessions[1].IsActive() {
+		t.Errorf("VHStatus#PlayerSessions()[1] is not active")
+	}
+
+	player := vhs.Params().Players[0]
+	if player.SessionCount != 2 {
+		t.Errorf("Params().Players[0].SessionCount = %d, want 2", player.SessionCount)
+	}
+	if player.TotalPlaytimeSeconds < 3600*2 {
+		t.Errorf("Params().Players[0].TotalPlaytimeSeconds = %d, want >= 7200", player.TotalPlaytimeSeconds)
+	}
+	if !player.CurrentSessionStartedAt.Equal(base.Add(time.Hour * 2)) {
+		t.Errorf("Params().Players[0].CurrentSessionStartedAt = %v, want %v",
+			player.CurrentSessionStartedAt, base.Add(time.Hour*2))
+	}
+	if player.CurrentSessionSeconds < 3600 {
+		t.Errorf("Params().Players[0].CurrentSessionSeconds = %d, want >= 3600", player.CurrentSessionSeconds)
+	}
+
+	if _, ok := vhs.PlayerSessions("unknown"); ok {
+		t.Error("VHStatus#PlayerSessions(unknown player) returned true")
+	}
+}
+
+func Test_DisconnectAllPlayers(t *testing.T) {
+	now := time.Now()
+
+	vhs := new_vhs_instance()
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: now.Add(-time.Hour)})
+	vhs.UpdatePlayer(Player{SteamID: "2", Status: "Got Character", UpdatedAt: now.Add(-time.Hour)})
+	vhs.UpdatePlayer(Player{SteamID: "3", Status: "Connection", UpdatedAt: now.Add(-time.Hour)})
+	vhs.UpdatePlayer(Player{SteamID: "3", Status: "Disconnection", UpdatedAt: now.Add(-time.Minute)})
+
+	ua := vhs.updatedAt
+	vhs.DisconnectAllPlayers(DisconnectReasonImplicit, now)
+	if ua.Equal(vhs.updatedAt) {
+		t.Error("VHStatus#DisconnectAllPlayers did not update vhs.updatedAt")
+	}
+	if vhs.activePlayerCount != 0 {
+		t.Errorf("VHStatus#DisconnectAllPlayers did not update ActivePlayerCount. got %d, want 0", vhs.activePlayerCount)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		sessions, _ := vhs.PlayerSessions(id)
+		if len(sessions) != 1 || !sessions[0].EndedAt.Equal(now) {
+			t.Errorf("VHStatus#DisconnectAllPlayers did not end the session of player %q: %+v", id, sessions)
+		}
+	}
+
+	for _, p := range vhs.Params().Players {
+		if p.Status != "Disconnection" {
+			t.Errorf("Params().Players[%q].Status = %q, want Disconnection", p.SteamID, p.Status)
+		}
+		wantReason, wantUpdatedAt := DisconnectReasonImplicit, now
+		if p.SteamID == "3" { // already disconnected
+			wantReason, wantUpdatedAt = "", now.Add(-time.Minute)
+		}
+		if p.DisconnectReason != wantReason {
+			t.Errorf("Params().Players[%q].DisconnectReason = %q, want %q", p.SteamID, p.DisconnectReason, wantReason)
+		}
+		if !p.UpdatedAt.Equal(wantUpdatedAt) {
+			t.Errorf("Params().Players[%q].UpdatedAt = %v, want %v", p.SteamID, p.UpdatedAt, wantUpdatedAt)
+		}
+		if p.CurrentSessionSeconds != 0 || !p.CurrentSessionStartedAt.IsZero() {
+			t.Errorf("Params().Players[%q] has current session after DisconnectAllPlayers", p.SteamID)
+		}
+		if p.CurrentSessionAsString() != "" {
+			t.Errorf("Player#CurrentSessionAsString(offline) = %q, want zero-string", p.CurrentSessionAsString())
+		}
+	}
+
+	// reconnect
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection", UpdatedAt: now})
+	if p := vhs.Params().Players[0]; p.DisconnectReason != "" {
+		t.Errorf("DisconnectReason is not cleared after reconnected: %q", p.DisconnectReason)
+	}
+	if sessions, _ := vhs.PlayerSessions("1"); len(sessions) != 2 || !sessions[1].IsActive() {
+		t.Errorf("a new session is not started after reconnected: %+v", sessions)
+	}
+}
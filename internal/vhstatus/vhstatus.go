@@ -6,29 +6,94 @@ import (
 	"time"
 )
 
+// DisconnectReasonImplicit is the reason of the disconnection which is not
+// logged, e.g. the server has crashed or restarted.
+const DisconnectReasonImplicit = "implicit disconnect"
+
 type Player struct {
+	// SteamID is the ID of the player. The players of the platforms other
+	// than Steam have "<Platform>_<ID>", e.g. "Xbox_2535400000000001".
 	SteamID   string    `json:"steam_id"`
+	Platform  string    `json:"platform"` // e.g. "Steam", "Xbox".
 	Status    string    `json:"status"`
 	Name      string    `json:"name"`
 	UpdatedAt time.Time `json:"updated_at"` // last update time of player on the log file.
+
+	// KnownNames is the character names the player has used, oldest first.
+	KnownNames []string `json:"known_names,omitempty"`
+
+	// DisconnectReason is the reason why the player has been disconnected.
+	// zero-string if the player is online or has left normally.
+	DisconnectReason string `json:"disconnect_reason"`
+
+	// Deaths
+	Deaths      int       `json:"deaths"`
+	LastDeathAt time.Time `json:"last_death_at"` // zero-value if the player has never died.
+
+	// Sessions, these are calculated when fetched.
+	SessionCount            int       `json:"session_count"`
+	TotalPlaytimeSeconds    int64     `json:"total_playtime_seconds"`
+	CurrentSessionStartedAt time.Time `json:"current_session_started_at"` // zero-value if the player is offline.
+	CurrentSessionSeconds   int64     `json:"current_session_seconds"`
 }
 
 func (p Player) UpdatedAtAsString() string {
 	return p.UpdatedAt.Format(time.RFC3339)
 }
 
+// LastDeathAtAsString returns zero-string if the player has never died.
+func (p Player) LastDeathAtAsString() string {
+	if p.LastDeathAt.IsZero() {
+		return ""
+	}
+	return p.LastDeathAt.Format(time.RFC3339)
+}
+
+func (p Player) TotalPlaytimeAsString() string {
+	return formatSeconds(p.TotalPlaytimeSeconds)
+}
+
+// CurrentSessionAsString returns zero-string if the player is offline.
+func (p Player) CurrentSessionAsString() string {
+	if p.CurrentSessionStartedAt.IsZero() {
+		return ""
+	}
+	return formatSeconds(p.CurrentSessionSeconds)
+}
+
+func formatSeconds(sec int64) string {
+	return (time.Duration(sec) * time.Second).String()
+}
+
 func (p *Player) update(rhs *Player) {
 	if rhs.Status != "" {
 		p.Status = rhs.Status
+		p.DisconnectReason = rhs.DisconnectReason
+	}
+	if rhs.Platform != "" {
+		p.Platform = rhs.Platform
 	}
 	if rhs.Name != "" {
 		p.Name = rhs.Name
+		p.addKnownName(rhs.Name)
 	}
 	if !rhs.UpdatedAt.IsZero() {
 		p.UpdatedAt = rhs.UpdatedAt
 	}
 }
 
+func (p *Player) addKnownName(name string) {
+	for _, n := range p.KnownNames {
+		if n == name {
+			return
+		}
+	}
+	// copy on write, the slice may be shared with the fetched Params.
+	names := make([]string, len(p.KnownNames), len(p.KnownNames)+1)
+	copy(names, p.KnownNames)
+	p.KnownNames = append(names, name)
+}
+
 type Params struct {
 	Status            string    `json:"status"`
 	UpdatedAt         time.Time `json:"updated_at"`
@@ -38,7 +103,23 @@ type Params struct {
 	WorldSeed         string    `json:"world_seed"`
 	Day               string    `json:"day"`
 	ActivePlayerCount int       `json:"active_player_count"`
+	TotalDeaths       int       `json:"total_deaths"`
 	Players           []Player  `json:"players"`
+
+	// World progression
+	GlobalKeys     []GlobalKey    `json:"global_keys"`
+	DefeatedBosses []DefeatedBoss `json:"defeated_bosses"`
+
+	WorldSave WorldSaveStats `json:"world_save"`
+
+	// Raids
+	CurrentRaid *Raid  `json:"current_raid"` // nil if no raid is running.
+	RecentRaids []Raid `json:"recent_raids"` // newest first.
+
+	// CustomEvents are the events defined by the log rules, sorted by name.
+	CustomEvents []CustomEventStats `json:"custom_events"`
+
+	Mods ModSet `json:"mods"`
 }
 
 func (p Params) UpdatedAtAsString() string {
@@ -59,12 +140,35 @@ type VHStatus struct {
 	worldSeed string
 	day       string
 
+	// World Progression
+	globalKeys []GlobalKey // in the order they were set.
+	worldSave  WorldSaveStats
+	worldSizes []WorldSize // oldest first.
+	raids      []Raid      // oldest first.
+
+	// Connection failures, oldest first.
+	connectionFailures map[string][]ConnectionFailure // key: SteamID
+
+	customEvents map[string]CustomEventStats // key: name of the event.
+
+	// Mods
+	mods        ModSet
+	modsStarted bool // the server has started with the mods.
+
 	// Activity
 	players           []Player
 	activePlayerCount int
+	sessions          map[string][]Session // key: SteamID
+	history           History
+
+	// Subscribers
+	subscribers   map[chan Notification]struct{}
+	lastPublished map[string]interface{} // Params as map at the last notification.
+	subMu         sync.Mutex
 
 	// internal
-	mu sync.Mutex
+	checkpoint Checkpoint // position of the last log line applied.
+	mu         sync.Mutex
 }
 
 func New() *VHStatus {
@@ -73,6 +177,9 @@ func New() *VHStatus {
 		updatedAt:         time.Now(),
 		activePlayerCount: 0,
 		players:           make([]Player, 0, 10),
+		sessions:          make(map[string][]Session),
+		history:           newHistory(),
+		subscribers:       make(map[chan Notification]struct{}),
 	}
 }
 
@@ -83,6 +190,13 @@ func (vhs *VHStatus) Params() Params {
 	players := make([]Player, len(vhs.players))
 	copy(players, vhs.players)
 
+	now := time.Now()
+	totalDeaths := 0
+	for i, _ := range players {
+		totalDeaths += players[i].Deaths
+		vhs.fillSessionParams(&players[i], now)
+	}
+
 	return Params{
 		Status:            vhs.status,
 		UpdatedAt:         vhs.updatedAt,
@@ -92,7 +206,15 @@ func (vhs *VHStatus) Params() Params {
 		WorldSeed:         vhs.worldSeed,
 		Day:               vhs.day,
 		ActivePlayerCount: vhs.activePlayerCount,
+		TotalDeaths:       totalDeaths,
 		Players:           players,
+		GlobalKeys:        append([]GlobalKey{}, vhs.globalKeys...),
+		DefeatedBosses:    vhs.defeatedBosses(),
+		WorldSave:         vhs.worldSave,
+		CurrentRaid:       vhs.currentRaid(now),
+		RecentRaids:       vhs.recentRaids(),
+		CustomEvents:      vhs.customEventStats(),
+		Mods:              vhs.modSet(),
 	}
 }
 
@@ -148,6 +270,9 @@ func (vhs *VHStatus) SetDay(day string) {
 // It returns true if the player is a new registration,
 // otherwise it returns false.
 //
+// A session of the player is started by any status except "Disconnection",
+// and it is ended by "Disconnection".
+//
 // If player.SteamID is zero-value, UpdatePlayer returns an error.
 func (vhs *VHStatus) UpdatePlayer(player Player) (bool, error) {
 	if player.SteamID == "" {
@@ -169,17 +294,136 @@ func (vhs *VHStatus) UpdatePlayer(player Player) (bool, error) {
 	}
 
 	if new_register {
+		player.KnownNames = nil
+		if player.Name != "" {
+			player.addKnownName(player.Name)
+		}
 		vhs.players = append(vhs.players, player)
 	}
 
-	// count active player
+	// sessions
+	if player.Status == "Disconnection" {
+		vhs.endSession(player.SteamID, player.UpdatedAt)
+	} else if player.Status != "" {
+		vhs.startSession(player.SteamID, player.UpdatedAt)
+	}
+
+	vhs.countActivePlayers()
+
+	vhs.updatedAt = time.Now()
+	return new_register, nil
+}
+
+// countActivePlayers updates vhs.activePlayerCount.
+// the caller must hold vhs.mu.
+func (vhs *VHStatus) countActivePlayers() {
 	vhs.activePlayerCount = 0
 	for i, _ := range vhs.players {
 		if vhs.players[i].Status != "Disconnection" {
 			vhs.activePlayerCount += 1
 		}
 	}
+}
+
+// AddPlayerDeath counts up the deaths of the player who has the name.
+// The death log only contains the character name, so the player is looked up
+// by name. If several players have the same name, the most recently updated
+// one is chosen.
+//
+// If no player has the name, AddPlayerDeath returns an error.
+func (vhs *VHStatus) AddPlayerDeath(name string, diedAt time.Time) error {
+	if name == "" {
+		return errors.New("name is not set.")
+	}
+
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	player := vhs.playerByName(name)
+	if player == nil {
+		return errors.New("player not found: " + name)
+	}
+
+	player.Deaths += 1
+	player.LastDeathAt = diedAt
+
+	vhs.updatedAt = time.Now()
+	return nil
+}
+
+// playerByName returns the most recently updated player who has the name,
+// or nil. the caller must hold vhs.mu.
+func (vhs *VHStatus) playerByName(name string) *Player {
+	if name == "" {
+		return nil
+	}
+
+	var player *Player
+	for i, _ := range vhs.players {
+		if vhs.players[i].Name != name {
+			continue
+		}
+		if player == nil || player.UpdatedAt.Before(vhs.players[i].UpdatedAt) {
+			player = &vhs.players[i]
+		}
+	}
+	return player
+}
+
+// SteamIDsByName returns the SteamIDs of the players who have used the name.
+func (vhs *VHStatus) SteamIDsByName(name string) []string {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	var ids []string
+	for i, _ := range vhs.players {
+		for _, n := range vhs.players[i].KnownNames {
+			if n == name {
+				ids = append(ids, vhs.players[i].SteamID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// NamesBySteamID returns the character names the player has used.
+func (vhs *VHStatus) NamesBySteamID(steamID string) []string {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	for i, _ := range vhs.players {
+		if vhs.players[i].SteamID == steamID {
+			return append([]string(nil), vhs.players[i].KnownNames...)
+		}
+	}
+	return nil
+}
+
+// DisconnectAllPlayers marks all online players as disconnected with the
+// reason, and ends their sessions.
+// It is used when the server has gone down or restarted, because no
+// disconnection is logged for the players in that case.
+func (vhs *VHStatus) DisconnectAllPlayers(reason string, disconnectedAt time.Time) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	for i, _ := range vhs.players {
+		p := &vhs.players[i]
+		if p.Status == "Disconnection" {
+			continue
+		}
+
+		p.Status = "Disconnection"
+		p.DisconnectReason = reason
+		if !disconnectedAt.IsZero() {
+			p.UpdatedAt = disconnectedAt
+		}
+	}
+	for steamID, _ := range vhs.sessions {
+		vhs.endSession(steamID, disconnectedAt)
+	}
+	vhs.countActivePlayers()
 
 	vhs.updatedAt = time.Now()
-	return new_register, nil
 }
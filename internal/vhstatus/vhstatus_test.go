@@ -179,6 +179,97 @@ func Test_UpdatePlayer(t *testing.T) {
 	}
 }
 
+func Test_AddPlayerDeath(t *testing.T) {
+	now := time.Now()
+
+	vhs := new_vhs_instance()
+	vhs.UpdatePlayer(Player{SteamID: "1", Name: "player1", UpdatedAt: now.Add(time.Minute * -2)})
+	vhs.UpdatePlayer(Player{SteamID: "2", Name: "player2", UpdatedAt: now.Add(time.Minute * -2)})
+	vhs.UpdatePlayer(Player{SteamID: "3", Name: "player1", UpdatedAt: now.Add(time.Minute * -1)})
+
+	cases := []struct {
+		name       string
+		wantErr    string
+		wantDeaths map[string]int
+	}{
+		{"player2", "", map[string]int{"1": 0, "2": 1, "3": 0}},
+		{"player2", "", map[string]int{"1": 0, "2": 2, "3": 0}},
+		{"player1", "", map[string]int{"1": 0, "2": 2, "3": 1}}, // latest one
+		{"unknown", "player not found", map[string]int{"1": 0, "2": 2, "3": 1}},
+		{"", "name is not set", map[string]int{"1": 0, "2": 2, "3": 1}},
+	}
+
+	for i, c := range cases {
+		ua := vhs.updatedAt
+		err := vhs.AddPlayerDeath(c.name, now)
+
+		if c.wantErr == "" {
+			if err != nil {
+				t.Errorf("VHStatus#AddPlayerDeath(case[%d]) returned err: %q", i, err.Error())
+			}
+			if ua.Equal(vhs.updatedAt) {
+				t.Errorf("VHStatus#AddPlayerDeath(case[%d]) did not update vhs.updatedAt", i)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("VHStatus#AddPlayerDeath(case[%d])\n"+
+				"\treturned err: %v\n"+
+				"\twant contain: %q",
+				i, err, c.wantErr)
+		}
+
+		for _, p := range vhs.players {
+			if p.Deaths != c.wantDeaths[p.SteamID] {
+				t.Errorf("VHStatus#AddPlayerDeath(case[%d]) player %q deaths = %d, want %d",
+					i, p.SteamID, p.Deaths, c.wantDeaths[p.SteamID])
+			}
+			if p.Deaths > 0 && !p.LastDeathAt.Equal(now) {
+				t.Errorf("VHStatus#AddPlayerDeath(case[%d]) did not update LastDeathAt of player %q",
+					i, p.SteamID)
+			}
+		}
+	}
+
+	if got := vhs.Params().TotalDeaths; got != 3 {
+		t.Errorf("VHStatus#Params().TotalDeaths = %d, want = %d", got, 3)
+	}
+}
+
+func Test_NameHistory(t *testing.T) {
+	vhs := new_vhs_instance()
+	vhs.UpdatePlayer(Player{SteamID: "1", Name: "player1"})
+	vhs.UpdatePlayer(Player{SteamID: "2"})
+	vhs.UpdatePlayer(Player{SteamID: "2", Name: "player2"})
+	vhs.UpdatePlayer(Player{SteamID: "2", Name: "alt"})
+	vhs.UpdatePlayer(Player{SteamID: "2", Name: "player2"})
+	vhs.UpdatePlayer(Player{SteamID: "3", Name: "alt"})
+
+	params := vhs.Params()
+	if got := strings.Join(params.Players[1].KnownNames, ","); got != "player2,alt" {
+		t.Errorf("Params().Players[1].KnownNames = %q, want %q", got, "player2,alt")
+	}
+
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"player1", "1"},
+		{"alt", "2,3"},
+		{"unknown", ""},
+	}
+	for _, c := range cases {
+		if got := strings.Join(vhs.SteamIDsByName(c.name), ","); got != c.want {
+			t.Errorf("VHStatus#SteamIDsByName(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	if got := strings.Join(vhs.NamesBySteamID("2"), ","); got != "player2,alt" {
+		t.Errorf("VHStatus#NamesBySteamID(2) = %q, want %q", got, "player2,alt")
+	}
+	if got := vhs.NamesBySteamID("unknown"); got != nil {
+		t.Errorf("VHStatus#NamesBySteamID(unknown) = %v, want nil", got)
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Getter
 //-----------------------------------------------------------------------------
@@ -187,10 +278,18 @@ func Test_Player(t *testing.T) {
 	srcTime := "2021-04-10T12:34:56Z"
 	srcTimeParam, _ := time.Parse(time.RFC3339, srcTime)
 
-	p := Player{"1", "Connection", "player1", srcTimeParam}
+	p := Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: srcTimeParam}
 	if got := p.UpdatedAtAsString(); got != srcTime {
 		t.Errorf("Player#UpdatedAtAsString returns %q, want %q", got, srcTime)
 	}
+
+	if got := p.LastDeathAtAsString(); got != "" {
+		t.Errorf("Player#LastDeathAtAsString(never died) returns %q, want zero-string", got)
+	}
+	p.LastDeathAt = srcTimeParam
+	if got := p.LastDeathAtAsString(); got != srcTime {
+		t.Errorf("Player#LastDeathAtAsString returns %q, want %q", got, srcTime)
+	}
 }
 
 func Test_Params(t *testing.T) {
@@ -244,3 +343,23 @@ func Test_Params_Players(t *testing.T) {
 		}
 	}
 }
+
+func Test_UpdatePlayer_Platform(t *testing.T) {
+	vhs := New()
+	vhs.UpdatePlayer(Player{SteamID: "Xbox_1", Platform: "Xbox", Status: "Got Handshake"})
+	vhs.UpdatePlayer(Player{SteamID: "Xbox_1", Status: "Got Character", Name: "player1"}) // platform is kept.
+	vhs.UpdatePlayer(Player{SteamID: "1", Status: "Connection"})
+
+	players := vhs.Params().Players
+	if players[0].Platform != "Xbox" || players[1].Platform != "" {
+		t.Errorf("Params().Players = %+v", players)
+	}
+
+	// the players of the old snapshots are Steam players.
+	dst := New()
+	dst.Restore(vhs.Snapshot())
+	players = dst.Params().Players
+	if players[0].Platform != "Xbox" || players[1].Platform != "Steam" {
+		t.Errorf("restored Players = %+v", players)
+	}
+}
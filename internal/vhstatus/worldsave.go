@@ -0,0 +1,113 @@
+package vhstatus
+
+import (
+	"fmt"
+	"time"
+)
+
+// WorldSaveStats is the statistics of the world saves.
+type WorldSaveStats struct {
+	Count           int       `json:"count"`
+	LastSavedAt     time.Time `json:"last_saved_at"` // zero-value if the world has never been saved.
+	LastDurationMs  float64   `json:"last_duration_ms"`
+	AvgDurationMs   float64   `json:"avg_duration_ms"`
+	MaxDurationMs   float64   `json:"max_duration_ms"`
+	TotalDurationMs float64   `json:"total_duration_ms"`
+
+	// Slow saves, which take longer than the threshold.
+	SlowThresholdMs float64 `json:"slow_threshold_ms"` // zero means disabled.
+	SlowCount       int     `json:"slow_count"`
+	LastSlow        bool    `json:"last_slow"`
+
+	// ZDOs is the number of the objects in the world at the last save.
+	ZDOs int `json:"zdos"`
+}
+
+func (s WorldSaveStats) LastSavedAtAsString() string {
+	if s.LastSavedAt.IsZero() {
+		return ""
+	}
+	return s.LastSavedAt.Format(time.RFC3339)
+}
+
+func (s WorldSaveStats) LastDurationAsString() string {
+	return formatMilliseconds(s.LastDurationMs)
+}
+
+func (s WorldSaveStats) AvgDurationAsString() string {
+	return formatMilliseconds(s.AvgDurationMs)
+}
+
+func (s WorldSaveStats) MaxDurationAsString() string {
+	return formatMilliseconds(s.MaxDurationMs)
+}
+
+func formatMilliseconds(ms float64) string {
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+// WorldSize is the number of the objects in the world at a save.
+type WorldSize struct {
+	Time time.Time `json:"time"`
+	ZDOs int       `json:"zdos"`
+}
+
+// maxWorldSizes is the number of the world sizes kept,
+// about 2 weeks if the world is saved every 20 minutes.
+const maxWorldSizes = 1000
+
+// SetSlowSaveThreshold sets the duration to warn the slow saves.
+// Zero disables the warning.
+func (vhs *VHStatus) SetSlowSaveThreshold(d time.Duration) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.worldSave.SlowThresholdMs = float64(d) / float64(time.Millisecond)
+}
+
+// RecordWorldSave records a world save which took the duration,
+// and returns true if the save is slower than the threshold.
+func (vhs *VHStatus) RecordWorldSave(duration time.Duration, savedAt time.Time) bool {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	ms := float64(duration) / float64(time.Millisecond)
+	s := &vhs.worldSave
+	s.Count += 1
+	s.LastSavedAt = savedAt
+	s.LastDurationMs = ms
+	s.TotalDurationMs += ms
+	s.AvgDurationMs = s.TotalDurationMs / float64(s.Count)
+	if ms > s.MaxDurationMs {
+		s.MaxDurationMs = ms
+	}
+	s.LastSlow = s.SlowThresholdMs > 0 && ms > s.SlowThresholdMs
+	if s.LastSlow {
+		s.SlowCount += 1
+	}
+
+	vhs.updatedAt = time.Now()
+	return s.LastSlow
+}
+
+// RecordWorldSize records the number of the objects saved with the world.
+func (vhs *VHStatus) RecordWorldSize(zdos int, at time.Time) {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	vhs.worldSave.ZDOs = zdos
+	if len(vhs.worldSizes) >= maxWorldSizes {
+		vhs.worldSizes = vhs.worldSizes[len(vhs.worldSizes)-maxWorldSizes+1:]
+	}
+	vhs.worldSizes = append(vhs.worldSizes, WorldSize{Time: at, ZDOs: zdos})
+
+	vhs.updatedAt = time.Now()
+}
+
+// WorldSizes returns the world sizes at the saves, oldest first.
+func (vhs *VHStatus) WorldSizes() []WorldSize {
+	vhs.mu.Lock()
+	defer vhs.mu.Unlock()
+
+	return append([]WorldSize{}, vhs.worldSizes...)
+}
@@ -0,0 +1,82 @@
+package vhstatus
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_RecordWorldSave(t *testing.T) {
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	vhs := New()
+	vhs.SetSlowSaveThreshold(time.Second * 2)
+
+	saves := []struct {
+		duration time.Duration
+		wantSlow bool
+	}{
+		{time.Millisecond * 1000, false},
+		{time.Millisecond * 3000, true},
+		{time.Millisecond * 2000, false}, // not longer than the threshold.
+	}
+	for i, s := range saves {
+		if got := vhs.RecordWorldSave(s.duration, t0.Add(time.Minute*time.Duration(i))); got != s.wantSlow {
+			t.Errorf("RecordWorldSave(%v) = %t, want %t", s.duration, got, s.wantSlow)
+		}
+	}
+
+	got := vhs.Params().WorldSave
+	want := WorldSaveStats{
+		Count:           3,
+		LastSavedAt:     t0.Add(time.Minute * 2),
+		LastDurationMs:  2000,
+		AvgDurationMs:   2000,
+		MaxDurationMs:   3000,
+		TotalDurationMs: 6000,
+		SlowThresholdMs: 2000,
+		SlowCount:       1,
+		LastSlow:        false,
+	}
+	if got != want {
+		t.Errorf("Params().WorldSave = %+v, want %+v", got, want)
+	}
+	if s := got.AvgDurationAsString(); s != "2000ms" {
+		t.Errorf("AvgDurationAsString() = %q", s)
+	}
+
+	// disabled
+	vhs.SetSlowSaveThreshold(0)
+	if vhs.RecordWorldSave(time.Hour, t0) {
+		t.Errorf("RecordWorldSave returned true with no threshold")
+	}
+}
+
+func Test_RecordWorldSize(t *testing.T) {
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	vhs := New()
+	for i := 0; i < maxWorldSizes+10; i++ {
+		vhs.RecordWorldSize(i, t0.Add(time.Minute*time.Duration(i)))
+	}
+
+	sizes := vhs.WorldSizes()
+	if len(sizes) != maxWorldSizes || sizes[0].ZDOs != 10 || sizes[len(sizes)-1].ZDOs != maxWorldSizes+9 {
+		t.Errorf("WorldSizes() has %d sizes from %+v to %+v", len(sizes), sizes[0], sizes[len(sizes)-1])
+	}
+	if got := vhs.Params().WorldSave.ZDOs; got != maxWorldSizes+9 {
+		t.Errorf("Params().WorldSave.ZDOs = %d", got)
+	}
+
+	// restored with the threshold of the destination.
+	vhs.SetSlowSaveThreshold(time.Second)
+	vhs.RecordWorldSave(time.Second*2, t0)
+	dst := New()
+	dst.SetSlowSaveThreshold(time.Second * 5)
+	dst.Restore(vhs.Snapshot())
+	if got := dst.Params().WorldSave; got.SlowCount != 1 || got.SlowThresholdMs != 5000 || got.ZDOs != maxWorldSizes+9 {
+		t.Errorf("restored WorldSave = %+v", got)
+	}
+	if got := dst.WorldSizes(); len(got) != maxWorldSizes {
+		t.Errorf("restored %d world sizes", len(got))
+	}
+}
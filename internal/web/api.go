@@ -3,9 +3,63 @@ package web
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
+// ApiGetStatus serves the status of the server given by "?server=",
+// or the primary server.
 func ApiGetStatus(w http.ResponseWriter, r *http.Request) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(getVHStatusParams())
+	json.NewEncoder(w).Encode(s.FetchParams())
+}
+
+// ApiPlayers serves the endpoints under /api/players/.
+//
+//	/api/players/{steam_id}/sessions
+func ApiPlayers(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/players/")
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "sessions" {
+		ApiGetPlayerSessions(w, r, parts[0])
+		return
+	}
+	renderApiError(w, http.StatusNotFound, "not found")
+}
+
+func ApiGetPlayerSessions(w http.ResponseWriter, r *http.Request, steamID string) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	sessions, ok := s.FetchPlayerSessions(steamID)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "player not found")
+		return
+	}
+
+	var total int64
+	for _, s := range sessions {
+		total += s.Seconds
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"steam_id":               steamID,
+		"session_count":          len(sessions),
+		"total_playtime_seconds": total,
+		"sessions":               sessions,
+	})
+}
+
+func renderApiError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
 }
@@ -3,6 +3,7 @@ package web
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -18,7 +19,7 @@ func Test_ApiGetStatus_Params(t *testing.T) {
 	wantCode := http.StatusOK
 	wantBody := []string{
 		"status", "server_id", "world_name", "world_seed", "updated_at",
-		"active_player_count", "players", "day",
+		"active_player_count", "players", "day", "total_deaths",
 	}
 
 	req := httptest.NewRequest(
@@ -78,10 +79,10 @@ func Test_ApiGetStatus(t *testing.T) {
 		WorldSeed:         "testseed",
 		ActivePlayerCount: 3,
 		Players: []vhstatus.Player{
-			vhstatus.Player{"1", "Connection", "player1", time.Now()},
-			vhstatus.Player{"2", "GotHandshake", "player2", time.Now()},
-			vhstatus.Player{"3", "GotCharacter", "player3", time.Now()},
-			vhstatus.Player{"4", "Disconnection", "player4", time.Now()},
+			vhstatus.Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "2", Status: "GotHandshake", Name: "player2", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "3", Status: "GotCharacter", Name: "player3", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "4", Status: "Disconnection", Name: "player4", UpdatedAt: time.Now()},
 		},
 	}
 
@@ -141,3 +142,81 @@ func Test_ApiGetStatus(t *testing.T) {
 			respBody.ActivePlayerCount, wantParams.ActivePlayerCount)
 	}
 }
+
+func Test_ApiGetStatus_PlayerNames(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	names := []string{"Björn", "山田 太郎", `<script>alert("x")</script>`}
+	vhs := vhstatus.New()
+	SetFechVHStatusParamsFunc(vhs.Params)
+	for i, name := range names {
+		vhs.UpdatePlayer(vhstatus.Player{SteamID: fmt.Sprint(i), Status: "Got Character", Name: name, UpdatedAt: time.Now()})
+	}
+
+	resp := httptest.NewRecorder()
+	ApiGetStatus(resp, httptest.NewRequest(http.MethodGet, "http://example.com/api", nil))
+
+	// the markup in the names must not be sent as is.
+	if strings.Contains(resp.Body.String(), "<script>") {
+		t.Errorf("ApiGetStatus response body contains the markup without escaping: %s", resp.Body.String())
+	}
+
+	var respBody vhstatus.Params
+	if err := json.Unmarshal(resp.Body.Bytes(), &respBody); err != nil {
+		t.Fatalf("ApiGetStatus response body is not JSON: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, p := range respBody.Players {
+		got[p.Name] = true
+	}
+	for _, name := range names {
+		if !got[name] {
+			t.Errorf("ApiGetStatus response body did not contain the player %q", name)
+		}
+	}
+}
+
+func Test_ApiPlayers_Sessions(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	SetFetchPlayerSessionsFunc(func(steamID string) ([]vhstatus.Session, bool) {
+		return vhs.PlayerSessions(steamID)
+	})
+	started := time.Now().Add(-time.Hour)
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Connection", UpdatedAt: started})
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Disconnection", UpdatedAt: started.Add(time.Minute)})
+
+	cases := []struct {
+		path     string
+		wantCode int
+		wantBody []string
+	}{
+		{"/api/players/1/sessions", http.StatusOK, []string{`"session_count":1`, `"total_playtime_seconds":60`, "started_at"}},
+		{"/api/players/1/sessions/", http.StatusOK, []string{`"steam_id":"1"`}},
+		{"/api/players/2/sessions", http.StatusNotFound, []string{"player not found"}},
+		{"/api/players/1", http.StatusNotFound, []string{"not found"}},
+		{"/api/players/", http.StatusNotFound, []string{"not found"}},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(
+			http.MethodGet,
+			"http://example.com"+c.path,
+			bytes.NewBufferString(""),
+		)
+		resp := httptest.NewRecorder()
+
+		ApiPlayers(resp, req)
+
+		if resp.Code != c.wantCode {
+			t.Errorf("ApiPlayers(%s) response %d, want %d", c.path, resp.Code, c.wantCode)
+		}
+		strBody := resp.Body.String()
+		for _, v := range c.wantBody {
+			if !strings.Contains(strBody, v) {
+				t.Errorf("ApiPlayers(%s) response body did not contain '%s'\ngot: '%s'", c.path, v, strBody)
+			}
+		}
+	}
+}
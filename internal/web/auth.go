@@ -0,0 +1,43 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// adminToken ... bearer token to access the admin endpoints.
+var adminToken string
+
+func SetAdminToken(token string) {
+	adminToken = token
+}
+
+// AdminOnly wraps the handler of an admin endpoint.
+// The request must have the header "Authorization: Bearer <admin token>".
+// If the admin token is not set, the endpoint is disabled.
+func AdminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			renderApiError(w, http.StatusNotFound, "not found")
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vhstatus"`)
+			renderApiError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimPrefix(auth, "Bearer "), true
+}
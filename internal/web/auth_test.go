@@ -0,0 +1,43 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_AdminOnly(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	handler := AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	cases := []struct {
+		token         string
+		authorization string
+		want          int
+	}{
+		{"", "", http.StatusNotFound},
+		{"", "Bearer ", http.StatusNotFound},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "Bearer secret", http.StatusOK},
+	}
+
+	for i, c := range cases {
+		SetAdminToken(c.token)
+
+		req := httptest.NewRequest("GET", "/api/admin", nil)
+		if c.authorization != "" {
+			req.Header.Set("Authorization", c.authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != c.want {
+			t.Errorf("AdminOnly(case[%d]) returned status %d, want %d", i, rec.Code, c.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// eventsKeepAliveInterval is the interval to send a comment to keep the
+// connection of the event stream open.
+var eventsKeepAliveInterval = time.Second * 15
+
+//-----------------------------------------------------------------------------
+// funcSubscribe
+var funcSubscribe func() (<-chan vhstatus.Notification, func())
+
+func SetSubscribeFunc(f func() (<-chan vhstatus.Notification, func())) {
+	funcSubscribe = f
+}
+
+// ApiEvents streams the changes of the status as Server-Sent Events.
+// The server can be chosen by "?server=", default is the primary server.
+//
+//	event: snapshot ... the current Params, sent at first.
+//	event: log      ... a log event.
+//	event: params   ... the fields of Params changed by the log event.
+func ApiEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderApiError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if s.Subscribe == nil {
+		renderApiError(w, http.StatusServiceUnavailable, "funcSubscribe is nil")
+		return
+	}
+
+	notifications, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	writeSSE(w, "snapshot", s.FetchParams())
+	flusher.Flush()
+
+	ticker := time.NewTicker(eventsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+			if n.Event != nil && isPublicEvent(n.Event) {
+				writeSSE(w, "log", n.Event)
+			}
+			if len(n.Diff) > 0 {
+				writeSSE(w, "params", n.Diff)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// isPublicEvent returns false for the events which must not be streamed to
+// the unauthenticated clients, i.e. the connection failures.
+func isPublicEvent(event interface{}) bool {
+	ev, ok := event.(vhlogwatcher.VHLogEvent)
+	return !ok || !ev.Event.IsConnectionFailure()
+}
+
+func writeSSE(w http.ResponseWriter, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
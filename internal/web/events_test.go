@@ -0,0 +1,118 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// readSSE reads an event from the stream, skipping comments.
+func readSSE(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read the event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func Test_ApiEvents(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	SetFechVHStatusParamsFunc(func() vhstatus.Params {
+		return vhs.Params()
+	})
+	SetSubscribeFunc(func() (<-chan vhstatus.Notification, func()) {
+		return vhs.Subscribe()
+	})
+	vhs.SetStatus("Online")
+
+	server := httptest.NewServer(http.HandlerFunc(ApiEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("ApiEvents Content-Type = %q, want text/event-stream", got)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if event, data := readSSE(t, reader); event != "snapshot" || !strings.Contains(data, `"status":"Online"`) {
+		t.Errorf("ApiEvents first event = %q %q, want snapshot", event, data)
+	}
+
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Connection", UpdatedAt: time.Now()})
+	vhs.Publish(map[string]string{"event": "Connection"})
+
+	if event, data := readSSE(t, reader); event != "log" || !strings.Contains(data, "Connection") {
+		t.Errorf("ApiEvents event = %q %q, want log", event, data)
+	}
+	if event, data := readSSE(t, reader); event != "params" || !strings.Contains(data, `"active_player_count":1`) {
+		t.Errorf("ApiEvents event = %q %q, want params", event, data)
+	}
+}
+
+func Test_ApiEvents_ConnectionFailure(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	SetFechVHStatusParamsFunc(vhs.Params)
+	SetSubscribeFunc(vhs.Subscribe)
+
+	server := httptest.NewServer(http.HandlerFunc(ApiEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	readSSE(t, reader) // snapshot
+
+	// the failure is only for the admins, the next event is the day.
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.WrongPassword, SteamID: "76561198000000001", Name: "secret"})
+	vhs.SetDay("3")
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "3"})
+
+	if event, data := readSSE(t, reader); event != "log" || !strings.Contains(data, "DayHasPassed") {
+		t.Errorf("ApiEvents event = %q %q, want the log of DayHasPassed", event, data)
+	}
+}
+
+func Test_ApiEvents_NotSetSubscribeFunc(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/events", nil)
+	resp := httptest.NewRecorder()
+
+	ApiEvents(resp, req)
+
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("ApiEvents response %d, want %d", resp.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,36 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+//-----------------------------------------------------------------------------
+// funcFetchConnectionFailures
+var funcFetchConnectionFailures func(steamID string) map[string][]vhstatus.ConnectionFailure
+
+func SetFetchConnectionFailuresFunc(f func(steamID string) map[string][]vhstatus.ConnectionFailure) {
+	funcFetchConnectionFailures = f
+}
+
+// ApiConnectionFailures serves the recent connection failures, newest first,
+// keyed by SteamID. It should be wrapped by AdminOnly.
+//
+//	/api/connection-failures
+//	/api/connection-failures?steam_id={SteamID}
+func ApiConnectionFailures(w http.ResponseWriter, r *http.Request) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if s.FetchConnectionFailures == nil {
+		renderApiError(w, http.StatusServiceUnavailable, "funcFetchConnectionFailures is nil")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.FetchConnectionFailures(r.URL.Query().Get("steam_id")))
+}
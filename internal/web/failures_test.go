@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func Test_ApiConnectionFailures(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+	SetAdminToken("secret")
+
+	vhs := vhstatus.New()
+	vhs.AddConnectionFailure(vhstatus.ConnectionFailure{SteamID: "1", Reason: "Wrong password", At: time.Now()})
+	vhs.AddConnectionFailure(vhstatus.ConnectionFailure{SteamID: "2", Reason: "Incompatible version", At: time.Now()})
+	SetFetchConnectionFailuresFunc(vhs.ConnectionFailures)
+
+	handler := AdminOnly(ApiConnectionFailures)
+	cases := []struct {
+		query     string
+		token     string
+		wantCode  int
+		wantCount int
+	}{
+		{"", "", http.StatusUnauthorized, 0},
+		{"", "secret", http.StatusOK, 2},
+		{"?steam_id=2", "secret", http.StatusOK, 1},
+		{"?steam_id=3", "secret", http.StatusOK, 0},
+		{"?server=gamma", "secret", http.StatusNotFound, 0},
+		{"?server=alpha", "secret", http.StatusServiceUnavailable, 0},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/connection-failures"+c.query, nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+
+		if resp.Code != c.wantCode {
+			t.Errorf("ApiConnectionFailures(%q) response %d, want %d", c.query, resp.Code, c.wantCode)
+			continue
+		}
+		if c.wantCode != http.StatusOK {
+			continue
+		}
+		var got map[string][]vhstatus.ConnectionFailure
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != c.wantCount {
+			t.Errorf("ApiConnectionFailures(%q) returned %+v", c.query, got)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+//-----------------------------------------------------------------------------
+// funcFetchHistory
+var funcFetchHistory func(from, to time.Time, step time.Duration) ([]vhstatus.HistoryPoint, error)
+
+func SetFetchHistoryFunc(f func(from, to time.Time, step time.Duration) ([]vhstatus.HistoryPoint, error)) {
+	funcFetchHistory = f
+}
+
+// parseHistoryTime parses RFC3339 or UNIX time in seconds.
+func parseHistoryTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseHistoryStep parses the step, e.g. "15m", "1h" and "1d".
+func parseHistoryStep(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid step %q", s)
+		}
+		return time.Hour * 24 * time.Duration(n), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// defaultHistoryStep returns the step which makes a few hundred points.
+func defaultHistoryStep(span time.Duration) time.Duration {
+	switch {
+	case span <= time.Hour*6:
+		return time.Minute
+	case span <= time.Hour*48:
+		return time.Minute * 10
+	case span <= time.Hour*24*14:
+		return time.Hour
+	default:
+		return time.Hour * 24
+	}
+}
+
+// ApiHistory serves the time series of the active player count and the
+// server status.
+//
+//	/api/history?from=...&to=...&step=...
+//
+// from and to are RFC3339 or UNIX time, default is the last 24 hours.
+// step is a duration like "15m", "1h" or "1d", default depends on the span.
+func ApiHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if s.FetchHistory == nil {
+		renderApiError(w, http.StatusServiceUnavailable, "funcFetchHistory is nil")
+		return
+	}
+
+	q := r.URL.Query()
+	to := time.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			renderApiError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+			return
+		}
+		to = t
+	}
+	from := to.Add(-time.Hour * 24)
+	if v := q.Get("from"); v != "" {
+		t, err := parseHistoryTime(v)
+		if err != nil {
+			renderApiError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+			return
+		}
+		from = t
+	}
+	step := defaultHistoryStep(to.Sub(from))
+	if v := q.Get("step"); v != "" {
+		d, err := parseHistoryStep(v)
+		if err != nil {
+			renderApiError(w, http.StatusBadRequest, "invalid step: "+err.Error())
+			return
+		}
+		step = d
+	}
+
+	points, err := s.FetchHistory(from, to, step)
+	if err != nil {
+		renderApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		From   time.Time               `json:"from"`
+		To     time.Time               `json:"to"`
+		Step   string                  `json:"step"`
+		Points []vhstatus.HistoryPoint `json:"points"`
+	}{from, to, step.String(), points})
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func Test_ApiHistory(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+
+	var gotFrom, gotTo time.Time
+	var gotStep time.Duration
+	SetFetchHistoryFunc(func(from, to time.Time, step time.Duration) ([]vhstatus.HistoryPoint, error) {
+		gotFrom, gotTo, gotStep = from, to, step
+		return vhstatus.New().History(from, to, step)
+	})
+
+	cases := []struct {
+		query    string
+		wantCode int
+		wantSpan time.Duration
+		wantStep time.Duration
+	}{
+		{"", http.StatusOK, time.Hour * 24, time.Minute * 10},
+		{"?from=1600000000&to=1600003600", http.StatusOK, time.Hour, time.Minute},
+		{"?from=2021-03-01T00:00:00Z&to=2021-03-31T00:00:00Z&step=1d", http.StatusOK, time.Hour * 24 * 30, time.Hour * 24},
+		{"?step=15m", http.StatusOK, time.Hour * 24, time.Minute * 15},
+		{"?step=30s", http.StatusBadRequest, 0, 0},
+		{"?step=xd", http.StatusBadRequest, 0, 0},
+		{"?from=yesterday", http.StatusBadRequest, 0, 0},
+		{"?from=1600003600&to=1600000000", http.StatusBadRequest, 0, 0},
+		{"?server=gamma", http.StatusNotFound, 0, 0},
+		{"?server=alpha", http.StatusServiceUnavailable, 0, 0},
+	}
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		ApiHistory(resp, httptest.NewRequest(http.MethodGet, "/api/history"+c.query, nil))
+		if resp.Code != c.wantCode {
+			t.Errorf("ApiHistory(%q) response %d, want %d", c.query, resp.Code, c.wantCode)
+			continue
+		}
+		if c.wantCode != http.StatusOK {
+			continue
+		}
+
+		if span := gotTo.Sub(gotFrom); span != c.wantSpan || gotStep != c.wantStep {
+			t.Errorf("ApiHistory(%q) fetched span %v step %v, want %v %v", c.query, span, gotStep, c.wantSpan, c.wantStep)
+		}
+		var body struct {
+			Step   string                  `json:"step"`
+			Points []vhstatus.HistoryPoint `json:"points"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Step != c.wantStep.String() || body.Points == nil {
+			t.Errorf("ApiHistory(%q) returned %+v", c.query, body)
+		}
+	}
+}
+
+func Test_ApiHistory_NotSetFunc(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	resp := httptest.NewRecorder()
+	ApiHistory(resp, httptest.NewRequest(http.MethodGet, "/api/history", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("ApiHistory response %d, want %d", resp.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"bytes"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -24,10 +25,10 @@ func Test_HtmlIndex(t *testing.T) {
 		Day:               "123",
 		ActivePlayerCount: 3,
 		Players: []vhstatus.Player{
-			vhstatus.Player{"1", "Connection", "player1", time.Now()},
-			vhstatus.Player{"2", "GotHandshake", "player2", time.Now()},
-			vhstatus.Player{"3", "GotCharacter", "player3", time.Now()},
-			vhstatus.Player{"4", "Disconnection", "player4", time.Now()},
+			vhstatus.Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "2", Status: "GotHandshake", Name: "player2", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "3", Status: "GotCharacter", Name: "player3", UpdatedAt: time.Now()},
+			vhstatus.Player{SteamID: "4", Status: "Disconnection", Name: "player4", UpdatedAt: time.Now()},
 		},
 	}
 
@@ -92,3 +93,36 @@ func Test_HtmlIndex_NotSetTemplateDirPath(t *testing.T) {
 		t.Errorf("html#Index response did not contain %q\n\tgot: %q", wantBody, strBody)
 	}
 }
+
+func Test_HtmlIndex_PlayerNames(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	SetTemplateDirPath("./../../web")
+	SetFechVHStatusParamsFunc(vhs.Params)
+	vhs.SetWorldName(`<world & "friends">`)
+	for i, name := range []string{"Björn", "山田 太郎", `<script>alert("x")</script>`} {
+		vhs.UpdatePlayer(vhstatus.Player{SteamID: fmt.Sprint(i), Status: "Got Character", Name: name, UpdatedAt: time.Now()})
+	}
+
+	resp := httptest.NewRecorder()
+	Index(resp, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("html#Index response %d, want %d", resp.Code, http.StatusOK)
+	}
+
+	strBody := resp.Body.String()
+	for _, want := range []string{
+		"<td>Björn</td>",
+		"<td>山田 太郎</td>",
+		"<td>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</td>",
+		"&lt;world &amp; &#34;friends&#34;&gt;",
+	} {
+		if !strings.Contains(strBody, want) {
+			t.Errorf("html#Index response did not contain %q", want)
+		}
+	}
+	if strings.Contains(strBody, `<script>alert`) || strings.Contains(strBody, `<world`) {
+		t.Errorf("html#Index response contains the names without escaping")
+	}
+}
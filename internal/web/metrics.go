@@ -0,0 +1,216 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+//-----------------------------------------------------------------------------
+// funcFetchLogStats
+var funcFetchLogStats func() vhlogwatcher.Stats
+
+func getLogStats() vhlogwatcher.Stats {
+	if funcFetchLogStats == nil {
+		return vhlogwatcher.Stats{}
+	}
+	return funcFetchLogStats()
+}
+
+func SetFetchLogStatsFunc(f func() vhlogwatcher.Stats) {
+	funcFetchLogStats = f
+}
+
+//-----------------------------------------------------------------------------
+// Prometheus text exposition format
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (mw metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes a sample. labels are pairs of the label name and value.
+func (mw metricsWriter) sample(name string, value float64, labels ...string) {
+	fmt.Fprint(mw.w, name)
+	if len(labels) > 0 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+		}
+		fmt.Fprint(mw.w, "{"+strings.Join(pairs, ",")+"}")
+	}
+	fmt.Fprintln(mw.w, " "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// metricsTarget is the params and the log stats of a server, and its labels.
+type metricsTarget struct {
+	labels   []string
+	params   vhstatus.Params
+	logStats vhlogwatcher.Stats
+}
+
+// getMetricsTargets returns the primary server, or all servers with the
+// "server" label if vhstatus watches several servers.
+func getMetricsTargets() []metricsTarget {
+	if len(servers) <= 1 {
+		return []metricsTarget{{params: getVHStatusParams(), logStats: getLogStats()}}
+	}
+
+	targets := make([]metricsTarget, 0, len(servers))
+	for _, s := range servers {
+		t := metricsTarget{labels: []string{"server", s.Name}, params: s.FetchParams()}
+		if s.FetchLogStats != nil {
+			t.logStats = s.FetchLogStats()
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// Metrics serves the metrics in the Prometheus text exposition format.
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	targets := getMetricsTargets()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := metricsWriter{w: w}
+
+	// Server
+	mw.header("vhstatus_server_online", "gauge", "Whether the game server is online.")
+	for _, t := range targets {
+		mw.sample("vhstatus_server_online", boolToFloat(t.params.Status == "Online"), t.labels...)
+	}
+
+	mw.header("vhstatus_active_players", "gauge", "Number of active players.")
+	for _, t := range targets {
+		mw.sample("vhstatus_active_players", float64(t.params.ActivePlayerCount), t.labels...)
+	}
+
+	// the day is unknown until the first day has passed.
+	days := make(map[int]int) // index of targets -> day
+	for i, t := range targets {
+		if day, err := strconv.Atoi(t.params.Day); err == nil {
+			days[i] = day
+		}
+	}
+	if len(days) > 0 {
+		mw.header("vhstatus_world_day", "gauge", "In-game day of the world.")
+		for i, t := range targets {
+			if day, ok := days[i]; ok {
+				mw.sample("vhstatus_world_day", float64(day), t.labels...)
+			}
+		}
+	}
+
+	// World save
+	mw.header("vhstatus_world_saves_total", "counter", "Number of world saves.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_saves_total", float64(t.params.WorldSave.Count), t.labels...)
+	}
+
+	mw.header("vhstatus_world_slow_saves_total", "counter", "Number of world saves slower than the threshold.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_slow_saves_total", float64(t.params.WorldSave.SlowCount), t.labels...)
+	}
+
+	mw.header("vhstatus_world_save_duration_seconds_total", "counter", "Total duration of the world saves in seconds.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_save_duration_seconds_total", t.params.WorldSave.TotalDurationMs/1000, t.labels...)
+	}
+
+	mw.header("vhstatus_world_last_save_duration_seconds", "gauge", "Duration of the last world save in seconds.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_last_save_duration_seconds", t.params.WorldSave.LastDurationMs/1000, t.labels...)
+	}
+
+	mw.header("vhstatus_world_max_save_duration_seconds", "gauge", "Longest duration of the world saves in seconds.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_max_save_duration_seconds", t.params.WorldSave.MaxDurationMs/1000, t.labels...)
+	}
+
+	mw.header("vhstatus_world_zdos", "gauge", "Number of the objects in the world at the last save.")
+	for _, t := range targets {
+		mw.sample("vhstatus_world_zdos", float64(t.params.WorldSave.ZDOs), t.labels...)
+	}
+
+	// Players
+	// the series are keyed on the SteamID, so that they are not split when
+	// the player changes the character. The name is in vhstatus_player_info.
+	playerLabels := func(t metricsTarget, p vhstatus.Player) []string {
+		return append(append([]string{}, t.labels...), "steam_id", p.SteamID)
+	}
+
+	mw.header("vhstatus_player_info", "gauge", "Name of the player, always 1.")
+	for _, t := range targets {
+		for _, p := range t.params.Players {
+			mw.sample("vhstatus_player_info", 1, append(playerLabels(t, p), "name", p.Name)...)
+		}
+	}
+
+	mw.header("vhstatus_player_online", "gauge", "Whether the player is online.")
+	for _, t := range targets {
+		for _, p := range t.params.Players {
+			mw.sample("vhstatus_player_online", boolToFloat(p.Status != "Disconnection"), playerLabels(t, p)...)
+		}
+	}
+
+	mw.header("vhstatus_player_sessions_total", "counter", "Number of sessions of the player.")
+	for _, t := range targets {
+		for _, p := range t.params.Players {
+			mw.sample("vhstatus_player_sessions_total", float64(p.SessionCount), playerLabels(t, p)...)
+		}
+	}
+
+	mw.header("vhstatus_player_playtime_seconds_total", "counter", "Total playtime of the player in seconds.")
+	for _, t := range targets {
+		for _, p := range t.params.Players {
+			mw.sample("vhstatus_player_playtime_seconds_total", float64(p.TotalPlaytimeSeconds), playerLabels(t, p)...)
+		}
+	}
+
+	mw.header("vhstatus_player_deaths_total", "counter", "Number of deaths of the player.")
+	for _, t := range targets {
+		for _, p := range t.params.Players {
+			mw.sample("vhstatus_player_deaths_total", float64(p.Deaths), playerLabels(t, p)...)
+		}
+	}
+
+	// Log
+	mw.header("vhstatus_log_events_total", "counter", "Number of log lines parsed per event type.")
+	for _, t := range targets {
+		events := make([]string, 0, len(t.logStats.Events))
+		for name, _ := range t.logStats.Events {
+			events = append(events, name)
+		}
+		sort.Strings(events)
+
+		for _, name := range events {
+			mw.sample("vhstatus_log_events_total", float64(t.logStats.Events[name]), append(append([]string{}, t.labels...), "event", name)...)
+		}
+	}
+
+	mw.header("vhstatus_log_unparsed_lines_total", "counter", "Number of log lines not parsed as any event.")
+	for _, t := range targets {
+		mw.sample("vhstatus_log_unparsed_lines_total", float64(t.logStats.Unparsed), t.labels...)
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func Test_Metrics(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	SetFechVHStatusParamsFunc(func() vhstatus.Params {
+		return vhs.Params()
+	})
+	SetFetchLogStatsFunc(func() vhlogwatcher.Stats {
+		return vhlogwatcher.Stats{
+			Events:   map[string]uint64{"Connection": 3, "Got Character": 2},
+			Unparsed: 42,
+		}
+	})
+	vhs.SetStatus("Online")
+	vhs.SetDay("12")
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Connection", Name: `pl"ayer1`, UpdatedAt: time.Now()})
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "2", Status: "Disconnection", Name: "player2", UpdatedAt: time.Now()})
+	vhs.AddPlayerDeath("player2", time.Now())
+	vhs.SetSlowSaveThreshold(time.Second)
+	vhs.RecordWorldSave(time.Millisecond*500, time.Now())
+	vhs.RecordWorldSave(time.Millisecond*1500, time.Now())
+	vhs.RecordWorldSize(123456, time.Now())
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil)
+	resp := httptest.NewRecorder()
+
+	Metrics(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Metrics response %d, want %d", resp.Code, http.StatusOK)
+	}
+	if got := resp.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Metrics Content-Type = %q", got)
+	}
+
+	strBody := resp.Body.String()
+	for _, want := range []string{
+		"# TYPE vhstatus_server_online gauge\n",
+		"vhstatus_server_online 1\n",
+		"vhstatus_active_players 1\n",
+		"vhstatus_world_day 12\n",
+		"vhstatus_world_saves_total 2\n",
+		"vhstatus_world_slow_saves_total 1\n",
+		"vhstatus_world_save_duration_seconds_total 2\n",
+		"vhstatus_world_last_save_duration_seconds 1.5\n",
+		"vhstatus_world_max_save_duration_seconds 1.5\n",
+		"vhstatus_world_zdos 123456\n",
+		`vhstatus_player_info{steam_id="1",name="pl\"ayer1"} 1` + "\n",
+		`vhstatus_player_info{steam_id="2",name="player2"} 1` + "\n",
+		`vhstatus_player_online{steam_id="1"} 1` + "\n",
+		`vhstatus_player_online{steam_id="2"} 0` + "\n",
+		`vhstatus_player_sessions_total{steam_id="1"} 1` + "\n",
+		`vhstatus_player_deaths_total{steam_id="2"} 1` + "\n",
+		`vhstatus_log_events_total{event="Connection"} 3` + "\n",
+		`vhstatus_log_events_total{event="Got Character"} 2` + "\n",
+		"vhstatus_log_unparsed_lines_total 42\n",
+	} {
+		if !strings.Contains(strBody, want) {
+			t.Errorf("Metrics response did not contain %q\ngot: %s", want, strBody)
+		}
+	}
+}
+
+func Test_Metrics_NotSetFuncs(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil)
+	resp := httptest.NewRecorder()
+
+	Metrics(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Errorf("Metrics response %d, want %d", resp.Code, http.StatusOK)
+	}
+	if strBody := resp.Body.String(); strings.Contains(strBody, "vhstatus_world_day") {
+		t.Errorf("Metrics response contains the day which is not set\ngot: %s", strBody)
+	}
+}
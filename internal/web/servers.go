@@ -0,0 +1,172 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+// Server is a Valheim server watched by vhstatus.
+type Server struct {
+	Name                string
+	FetchParams         func() vhstatus.Params
+	FetchPlayerSessions func(steamID string) ([]vhstatus.Session, bool)
+	Subscribe           func() (<-chan vhstatus.Notification, func())
+	FetchHistory        func(from, to time.Time, step time.Duration) ([]vhstatus.HistoryPoint, error)
+	FetchWorldSizes     func() []vhstatus.WorldSize
+
+	FetchConnectionFailures func(steamID string) map[string][]vhstatus.ConnectionFailure
+	FetchLogStats           func() vhlogwatcher.Stats
+}
+
+// ServerStatus is the status of a named server.
+type ServerStatus struct {
+	Name string `json:"name"`
+	vhstatus.Params
+}
+
+// Overview is the aggregated status of the servers.
+type Overview struct {
+	Servers           []ServerStatus
+	OnlineServerCount int
+	ActivePlayerCount int
+	UpdatedAt         time.Time
+}
+
+func (o Overview) UpdatedAtAsString() string {
+	return o.UpdatedAt.Format(time.RFC3339)
+}
+
+//-----------------------------------------------------------------------------
+// servers
+var servers []Server
+
+func SetServers(s []Server) {
+	servers = s
+}
+
+func findServer(name string) (Server, bool) {
+	for _, s := range servers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Server{}, false
+}
+
+// requestedServer returns the server named by the "server" query parameter.
+// If it is not given, it returns the primary server, which is set by
+// SetFechVHStatusParamsFunc and so on.
+func requestedServer(r *http.Request) (Server, bool) {
+	if name := r.URL.Query().Get("server"); name != "" {
+		return findServer(name)
+	}
+	return Server{
+		FetchParams:         getVHStatusParams,
+		FetchPlayerSessions: getPlayerSessions,
+		Subscribe:           funcSubscribe,
+		FetchHistory:        funcFetchHistory,
+		FetchWorldSizes:     funcFetchWorldSizes,
+
+		FetchConnectionFailures: funcFetchConnectionFailures,
+	}, true
+}
+
+func getServerStatuses() []ServerStatus {
+	ret := make([]ServerStatus, 0, len(servers))
+	for _, s := range servers {
+		ret = append(ret, ServerStatus{Name: s.Name, Params: s.FetchParams()})
+	}
+	return ret
+}
+
+func getOverview() Overview {
+	o := Overview{Servers: getServerStatuses()}
+	for _, s := range o.Servers {
+		if s.Status == "Online" {
+			o.OnlineServerCount += 1
+		}
+		o.ActivePlayerCount += s.ActivePlayerCount
+		if s.UpdatedAt.After(o.UpdatedAt) {
+			o.UpdatedAt = s.UpdatedAt
+		}
+	}
+	return o
+}
+
+// ApiServers serves the endpoints under /api/servers.
+//
+//	/api/servers        ... the status of all servers.
+//	/api/servers/{name} ... the status of the server.
+func ApiServers(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/servers"), "/")
+	if name == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(getServerStatuses())
+		return
+	}
+
+	s, ok := findServer(name)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ServerStatus{Name: s.Name, Params: s.FetchParams()})
+}
+
+// OverviewPage renders the aggregated status of the servers.
+func OverviewPage(w http.ResponseWriter, r *http.Request) {
+	temp, err := template.ParseFiles(getTemplateDirPath() + "overview.html")
+	if err != nil {
+		log.Print(err)
+		render500(w)
+		return
+	}
+
+	if err := temp.Execute(w, getOverview()); err != nil {
+		log.Print(err)
+		render500(w)
+	}
+}
+
+// ServerPages serves the pages under /servers/.
+//
+//	/servers/        ... the overview of all servers.
+//	/servers/{name}/ ... the index page of the server.
+func ServerPages(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/servers/")
+	if path == "" {
+		OverviewPage(w, r)
+		return
+	}
+
+	name := strings.TrimSuffix(path, "/")
+	s, ok := findServer(name)
+	if !ok {
+		render404(w)
+		return
+	}
+	if !strings.HasSuffix(path, "/") {
+		// the index page finds the server name from the canonical URL.
+		http.Redirect(w, r, "/servers/"+name+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	temp, err := template.ParseFiles(getTemplateDirPath() + "index.html")
+	if err != nil {
+		log.Print(err)
+		render500(w)
+		return
+	}
+	if err := temp.Execute(w, s.FetchParams()); err != nil {
+		log.Print(err)
+		render500(w)
+	}
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func setupTestServers() {
+	for i, name := range []string{"alpha", "beta"} {
+		vhs := vhstatus.New()
+		vhs.SetWorldName(name + "-world")
+		if i == 0 {
+			vhs.SetStatus("Online")
+			vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: time.Now()})
+		}
+		logStats := vhlogwatcher.Stats{Events: map[string]uint64{"Connection": uint64(i + 1)}, Unparsed: uint64(i + 10)}
+		servers = append(servers, Server{
+			Name:                name,
+			FetchParams:         vhs.Params,
+			FetchPlayerSessions: vhs.PlayerSessions,
+			Subscribe:           vhs.Subscribe,
+			FetchLogStats:       func() vhlogwatcher.Stats { return logStats },
+		})
+	}
+}
+
+func Test_ApiServers(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+
+	// list
+	resp := httptest.NewRecorder()
+	ApiServers(resp, httptest.NewRequest(http.MethodGet, "/api/servers", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("ApiServers(list) response %d, want %d", resp.Code, http.StatusOK)
+	}
+	var list []ServerStatus
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "alpha" || list[1].WorldName != "beta-world" {
+		t.Errorf("ApiServers(list) returned %+v", list)
+	}
+
+	// a server
+	cases := []struct {
+		path      string
+		wantCode  int
+		wantWorld string
+	}{
+		{"/api/servers/alpha", http.StatusOK, "alpha-world"},
+		{"/api/servers/beta/", http.StatusOK, "beta-world"},
+		{"/api/servers/gamma", http.StatusNotFound, ""},
+		{"/api/servers/alpha/beta", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		ApiServers(resp, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if resp.Code != c.wantCode {
+			t.Errorf("ApiServers(%s) response %d, want %d", c.path, resp.Code, c.wantCode)
+			continue
+		}
+		if c.wantCode != http.StatusOK {
+			continue
+		}
+
+		var got map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &got)
+		if got["world_name"] != c.wantWorld || got["name"] == "" {
+			t.Errorf("ApiServers(%s) returned %v, want world %q", c.path, got, c.wantWorld)
+		}
+	}
+}
+
+func Test_ApiGetStatus_Server(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+
+	resp := httptest.NewRecorder()
+	ApiGetStatus(resp, httptest.NewRequest(http.MethodGet, "/api?server=beta", nil))
+	if !strings.Contains(resp.Body.String(), "beta-world") {
+		t.Errorf("ApiGetStatus(?server=beta) returned %q", resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	ApiGetStatus(resp, httptest.NewRequest(http.MethodGet, "/api?server=gamma", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("ApiGetStatus(?server=gamma) response %d, want %d", resp.Code, http.StatusNotFound)
+	}
+}
+
+func Test_ServerPages(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+	SetTemplateDirPath("./../../web")
+
+	cases := []struct {
+		path     string
+		wantCode int
+		want     []string
+	}{
+		{"/servers/", http.StatusOK, []string{"1 / 2", `href="/servers/alpha/"`, "beta-world"}},
+		{"/servers/alpha/", http.StatusOK, []string{"alpha-world", "player1"}},
+		{"/servers/beta", http.StatusMovedPermanently, nil},
+		{"/servers/gamma/", http.StatusNotFound, nil},
+	}
+
+	for _, c := range cases {
+		resp := httptest.NewRecorder()
+		ServerPages(resp, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if resp.Code != c.wantCode {
+			t.Errorf("ServerPages(%s) response %d, want %d", c.path, resp.Code, c.wantCode)
+			continue
+		}
+		for _, want := range c.want {
+			if !strings.Contains(resp.Body.String(), want) {
+				t.Errorf("ServerPages(%s) response did not contain %q", c.path, want)
+			}
+		}
+	}
+}
+
+func Test_Metrics_Servers(t *testing.T) {
+	t.Cleanup(cleanup)
+	setupTestServers()
+
+	resp := httptest.NewRecorder()
+	Metrics(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		`vhstatus_server_online{server="alpha"} 1`,
+		`vhstatus_server_online{server="beta"} 0`,
+		`vhstatus_player_info{server="alpha",steam_id="1",name="player1"} 1`,
+		`vhstatus_player_online{server="alpha",steam_id="1"} 1`,
+		`vhstatus_log_events_total{server="alpha",event="Connection"} 1`,
+		`vhstatus_log_events_total{server="beta",event="Connection"} 2`,
+		`vhstatus_log_unparsed_lines_total{server="beta"} 11`,
+	} {
+		if !strings.Contains(resp.Body.String(), want) {
+			t.Errorf("Metrics response did not contain %q\n\tgot: %s", want, resp.Body.String())
+		}
+	}
+}
@@ -40,6 +40,21 @@ func SetFechVHStatusParamsFunc(f func() vhstatus.Params) {
 	funcFetchVHStatus = f
 }
 
+//-----------------------------------------------------------------------------
+// funcFetchPlayerSessions
+var funcFetchPlayerSessions func(steamID string) ([]vhstatus.Session, bool)
+
+func getPlayerSessions(steamID string) ([]vhstatus.Session, bool) {
+	if funcFetchPlayerSessions == nil {
+		return nil, false
+	}
+	return funcFetchPlayerSessions(steamID)
+}
+
+func SetFetchPlayerSessionsFunc(f func(steamID string) ([]vhstatus.Session, bool)) {
+	funcFetchPlayerSessions = f
+}
+
 //-----------------------------------------------------------------------------
 // Rendering helper
 func render404(w http.ResponseWriter) {
@@ -10,6 +10,14 @@ import (
 func cleanup() {
 	templateDirPath = ""
 	funcFetchVHStatus = nil
+	funcFetchPlayerSessions = nil
+	funcSubscribe = nil
+	funcFetchLogStats = nil
+	adminToken = ""
+	servers = nil
+	funcFetchHistory = nil
+	funcFetchWorldSizes = nil
+	funcFetchConnectionFailures = nil
 }
 
 //-----------------------------------------------------------------------------
@@ -0,0 +1,277 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+var (
+	wsPingInterval = time.Second * 30
+	wsPongWait     = time.Second * 60
+	wsWriteWait    = time.Second * 10
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// the status is public, so dashboards on any origin can connect.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Topics of the WebSocket messages.
+const (
+	TopicServer = "server"
+	TopicPlayer = "player"
+)
+
+// wsMessage is a message of the WebSocket API.
+//
+// server to client:
+//
+//	{"type": "snapshot", "params": {...}} ... sent at first, and when the filter has been changed.
+//	{"type": "event", "event": {...}}     ... a log event.
+//	{"type": "diff", "diff": {...}}       ... the fields of Params changed by the log event.
+//
+// client to server:
+//
+//	{"type": "filter", "topics": ["server", "player"], "steam_id": "..."}
+type wsMessage struct {
+	Type string `json:"type"`
+
+	// server to client
+	Params *vhstatus.Params       `json:"params,omitempty"`
+	Event  interface{}            `json:"event,omitempty"`
+	Diff   map[string]interface{} `json:"diff,omitempty"`
+
+	// client to server
+	Topics  []string `json:"topics,omitempty"`
+	SteamID string   `json:"steam_id,omitempty"`
+}
+
+// wsFilter filters the messages sent to a client.
+// Empty topics means all topics.
+type wsFilter struct {
+	topics  map[string]bool
+	steamID string
+}
+
+func newWSFilter(topics []string, steamID string) wsFilter {
+	f := wsFilter{topics: make(map[string]bool), steamID: steamID}
+	for _, t := range topics {
+		if t = strings.TrimSpace(t); t != "" {
+			f.topics[t] = true
+		}
+	}
+	// a SteamID filter only makes sense for player events.
+	if steamID != "" && len(f.topics) == 0 {
+		f.topics[TopicPlayer] = true
+	}
+	return f
+}
+
+func (f wsFilter) hasTopic(topic string) bool {
+	return len(f.topics) == 0 || f.topics[topic]
+}
+
+func (f wsFilter) matchPlayer(steamID, name string, params vhstatus.Params) bool {
+	if f.steamID == "" {
+		return true
+	}
+	if steamID != "" {
+		return steamID == f.steamID
+	}
+	// some events (e.g. deaths) only have the character name.
+	for _, p := range params.Players {
+		if p.SteamID == f.steamID {
+			return name != "" && p.Name == name
+		}
+	}
+	return false
+}
+
+func (f wsFilter) matchEvent(event interface{}, params vhstatus.Params) bool {
+	ev, ok := event.(vhlogwatcher.VHLogEvent)
+	if !ok {
+		return len(f.topics) == 0
+	}
+
+	switch {
+	case ev.Event.IsServerEvent():
+		return f.hasTopic(TopicServer)
+	case ev.Event.IsUserEvent():
+		return f.hasTopic(TopicPlayer) && f.matchPlayer(ev.SteamID, ev.Name, params)
+	}
+	return len(f.topics) == 0
+}
+
+// filterDiff returns the diff that only contains the fields of the topics.
+func (f wsFilter) filterDiff(diff map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{})
+	for k, v := range diff {
+		switch k {
+		case "players":
+			if !f.hasTopic(TopicPlayer) {
+				continue
+			}
+			players := f.filterPlayerMaps(v)
+			if len(players) == 0 {
+				continue
+			}
+			v = players
+		case "active_player_count", "total_deaths":
+			// aggregated values are not about a single player.
+			if !f.hasTopic(TopicPlayer) || f.steamID != "" {
+				continue
+			}
+		default:
+			if !f.hasTopic(TopicServer) {
+				continue
+			}
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+func (f wsFilter) filterPlayerMaps(v interface{}) []interface{} {
+	players, _ := v.([]interface{})
+	if f.steamID == "" {
+		return players
+	}
+
+	ret := make([]interface{}, 0, 1)
+	for _, p := range players {
+		if p, ok := p.(map[string]interface{}); ok && p["steam_id"] == f.steamID {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+func (f wsFilter) filterParams(params vhstatus.Params) vhstatus.Params {
+	if f.steamID == "" {
+		return params
+	}
+
+	players := make([]vhstatus.Player, 0, 1)
+	for _, p := range params.Players {
+		if p.SteamID == f.steamID {
+			players = append(players, p)
+		}
+	}
+	params.Players = players
+	return params
+}
+
+// WebSocket serves the WebSocket API.
+// It sends the snapshot of Params at first, and then the incremental updates.
+// The initial filter can be given as query parameters:
+//
+//	/ws?topics=server,player&steam_id=...
+//
+// The server can be chosen by "?server=", default is the primary server.
+func WebSocket(w http.ResponseWriter, r *http.Request) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if s.Subscribe == nil {
+		renderApiError(w, http.StatusServiceUnavailable, "funcSubscribe is nil")
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client.
+		return
+	}
+	defer conn.Close()
+
+	notifications, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	var topics []string
+	if q := r.URL.Query().Get("topics"); q != "" {
+		topics = strings.Split(q, ",")
+	}
+	filter := newWSFilter(topics, r.URL.Query().Get("steam_id"))
+
+	// reader
+	filters := make(chan wsFilter)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			var msg wsMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == "filter" {
+				select {
+				case filters <- newWSFilter(msg.Topics, msg.SteamID):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+	}()
+
+	// writer
+	write := func(msg wsMessage) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		return conn.WriteJSON(msg)
+	}
+	writeSnapshot := func() error {
+		params := filter.filterParams(s.FetchParams())
+		return write(wsMessage{Type: "snapshot", Params: &params})
+	}
+
+	if err := writeSnapshot(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case filter = <-filters:
+			if err := writeSnapshot(); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+			if n.Event != nil && isPublicEvent(n.Event) && filter.matchEvent(n.Event, n.Params) {
+				if err := write(wsMessage{Type: "event", Event: n.Event}); err != nil {
+					return
+				}
+			}
+			if diff := filter.filterDiff(n.Diff); len(diff) > 0 {
+				if err := write(wsMessage{Type: "diff", Diff: diff}); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
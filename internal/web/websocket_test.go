@@ -0,0 +1,173 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhlogwatcher"
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func setupWebSocket(t *testing.T, vhs *vhstatus.VHStatus, query string) *websocket.Conn {
+	t.Helper()
+
+	SetFechVHStatusParamsFunc(func() vhstatus.Params {
+		return vhs.Params()
+	})
+	SetSubscribeFunc(func() (<-chan vhstatus.Notification, func()) {
+		return vhs.Subscribe()
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(WebSocket))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	return conn
+}
+
+func readWSMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("failed to read the message: %v", err)
+	}
+	return msg
+}
+
+func Test_WebSocket(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	vhs.SetStatus("Online")
+	conn := setupWebSocket(t, vhs, "")
+
+	msg := readWSMessage(t, conn)
+	if msg["type"] != "snapshot" || msg["params"].(map[string]interface{})["status"] != "Online" {
+		t.Fatalf("WebSocket first message = %v, want snapshot", msg)
+	}
+
+	vhs.SetDay("3")
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "3"})
+
+	msg = readWSMessage(t, conn)
+	if msg["type"] != "event" || msg["event"].(map[string]interface{})["event"] != "DayHasPassed" {
+		t.Errorf("WebSocket message = %v, want DayHasPassed event", msg)
+	}
+	msg = readWSMessage(t, conn)
+	if msg["type"] != "diff" || msg["diff"].(map[string]interface{})["day"] != "3" {
+		t.Errorf("WebSocket message = %v, want diff of day", msg)
+	}
+}
+
+func Test_WebSocket_Filter(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Connection", Name: "player1", UpdatedAt: time.Now()})
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "2", Status: "Connection", Name: "player2", UpdatedAt: time.Now()})
+	conn := setupWebSocket(t, vhs, "?steam_id=2")
+
+	msg := readWSMessage(t, conn)
+	players := msg["params"].(map[string]interface{})["players"].([]interface{})
+	if len(players) != 1 || players[0].(map[string]interface{})["steam_id"] != "2" {
+		t.Errorf("WebSocket snapshot players = %v, want only player 2", players)
+	}
+
+	// filtered out
+	vhs.SetDay("3")
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "3"})
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "1", Status: "Disconnection", UpdatedAt: time.Now()})
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.Disconnection, SteamID: "1"})
+
+	// death of player2, identified by the name.
+	vhs.AddPlayerDeath("player2", time.Now())
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.PlayerDeath, Name: "player2"})
+
+	msg = readWSMessage(t, conn)
+	if msg["type"] != "event" || msg["event"].(map[string]interface{})["name"] != "player2" {
+		t.Errorf("WebSocket message = %v, want the death of player2", msg)
+	}
+	msg = readWSMessage(t, conn)
+	diff := msg["diff"].(map[string]interface{})
+	if _, ok := diff["updated_at"]; ok {
+		t.Errorf("WebSocket diff has server fields: %v", diff)
+	}
+	if players := diff["players"].([]interface{}); len(players) != 1 {
+		t.Errorf("WebSocket diff players = %v, want only player 2", players)
+	}
+
+	// change the filter
+	conn.WriteJSON(map[string]interface{}{"type": "filter", "topics": []string{"server"}})
+	msg = readWSMessage(t, conn)
+	if msg["type"] != "snapshot" || len(msg["params"].(map[string]interface{})["players"].([]interface{})) != 2 {
+		t.Errorf("WebSocket message = %v, want snapshot with all players", msg)
+	}
+
+	vhs.UpdatePlayer(vhstatus.Player{SteamID: "2", Status: "Disconnection", UpdatedAt: time.Now()})
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.Disconnection, SteamID: "2"})
+	msg = readWSMessage(t, conn)
+	if msg["type"] != "diff" || msg["diff"].(map[string]interface{})["players"] != nil {
+		t.Errorf("WebSocket message = %v, want diff without players", msg)
+	}
+}
+
+func Test_WebSocket_ConnectionFailure(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	vhs := vhstatus.New()
+	conn := setupWebSocket(t, vhs, "")
+	readWSMessage(t, conn) // snapshot
+
+	// the failure is only for the admins, the next event is the day.
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.Banned, SteamID: "76561198000000001", Name: "secret"})
+	vhs.SetDay("3")
+	vhs.Publish(vhlogwatcher.VHLogEvent{Event: vhlogwatcher.DayHasPassed, Value: "3"})
+
+	msg := readWSMessage(t, conn)
+	if msg["type"] != "event" || msg["event"].(map[string]interface{})["event"] != "DayHasPassed" {
+		t.Errorf("WebSocket message = %v, want DayHasPassed event", msg)
+	}
+}
+
+func Test_WebSocket_Ping(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	defaultInterval := wsPingInterval
+	wsPingInterval = time.Millisecond * 10
+	t.Cleanup(func() { wsPingInterval = defaultInterval })
+
+	conn := setupWebSocket(t, vhstatus.New(), "")
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second * 3):
+		t.Error("WebSocket did not send ping")
+	}
+}
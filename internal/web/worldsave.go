@@ -0,0 +1,33 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+//-----------------------------------------------------------------------------
+// funcFetchWorldSizes
+var funcFetchWorldSizes func() []vhstatus.WorldSize
+
+func SetFetchWorldSizesFunc(f func() []vhstatus.WorldSize) {
+	funcFetchWorldSizes = f
+}
+
+// ApiWorldSize serves the number of the objects in the world at each save,
+// oldest first. The statistics of the saves are in the status (/api).
+func ApiWorldSize(w http.ResponseWriter, r *http.Request) {
+	s, ok := requestedServer(r)
+	if !ok {
+		renderApiError(w, http.StatusNotFound, "server not found")
+		return
+	}
+	if s.FetchWorldSizes == nil {
+		renderApiError(w, http.StatusServiceUnavailable, "funcFetchWorldSizes is nil")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.FetchWorldSizes())
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mitsu-ksgr/vhstatus/internal/vhstatus"
+)
+
+func Test_ApiWorldSize(t *testing.T) {
+	t.Cleanup(cleanup)
+
+	resp := httptest.NewRecorder()
+	ApiWorldSize(resp, httptest.NewRequest(http.MethodGet, "/api/world-size", nil))
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("ApiWorldSize(not set) response %d, want %d", resp.Code, http.StatusServiceUnavailable)
+	}
+
+	t0 := time.Date(2021, 4, 10, 12, 0, 0, 0, time.UTC)
+	vhs := vhstatus.New()
+	vhs.RecordWorldSize(100, t0)
+	vhs.RecordWorldSize(120, t0.Add(time.Minute*20))
+	SetFetchWorldSizesFunc(vhs.WorldSizes)
+
+	resp = httptest.NewRecorder()
+	ApiWorldSize(resp, httptest.NewRequest(http.MethodGet, "/api/world-size", nil))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("ApiWorldSize response %d, want %d", resp.Code, http.StatusOK)
+	}
+	var sizes []vhstatus.WorldSize
+	if err := json.Unmarshal(resp.Body.Bytes(), &sizes); err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes[1].ZDOs != 120 || !sizes[1].Time.Equal(t0.Add(time.Minute*20)) {
+		t.Errorf("ApiWorldSize returned %+v", sizes)
+	}
+
+	resp = httptest.NewRecorder()
+	ApiWorldSize(resp, httptest.NewRequest(http.MethodGet, "/api/world-size?server=gamma", nil))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("ApiWorldSize(unknown server) response %d, want %d", resp.Code, http.StatusNotFound)
+	}
+}